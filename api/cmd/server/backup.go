@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+// The backup and restore commands work on the configured store directly,
+// so the server must be stopped first. A running server is backed up
+// through GET /admin/backup instead.
+
+func runBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := fs.String("o", "", "write the backup to this file instead of stdout")
+	format := fs.String("format", "", "badger or jsonl; badger by default for the badger backend, jsonl otherwise")
+	since := fs.Uint64("since", 0, "only back up changes since this X-Backup-Since value (badger format)")
+	fs.Parse(args)
+
+	godotenv.Load()
+	store, err := openStore()
+	if err != nil {
+		log.Fatal("Failed to open storage (is the server still running?): ", err)
+	}
+	defer store.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal("Failed to create backup file: ", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := backup(store, w, *format, *since); err != nil {
+		log.Fatal("Backup failed: ", err)
+	}
+}
+
+func backup(store storage.Store, w io.Writer, format string, since uint64) error {
+	badgerStore, isBadger := store.(*storage.BadgerStore)
+	if format == "" {
+		format = "jsonl"
+		if isBadger {
+			format = "badger"
+		}
+	}
+
+	switch format {
+	case "badger":
+		if !isBadger {
+			return fmt.Errorf("badger backups need the badger storage backend")
+		}
+		next, err := badgerStore.Backup(w, since)
+		if err != nil {
+			return err
+		}
+		log.Printf("Backup written; pass -since %d for the next incremental backup", next)
+		return nil
+	case "jsonl":
+		return storage.Export(store, w)
+	}
+	return fmt.Errorf("unknown backup format %q", format)
+}
+
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	input := fs.String("i", "", "read the backup from this file instead of stdin")
+	format := fs.String("format", "", "badger or jsonl; badger by default for the badger backend, jsonl otherwise")
+	fs.Parse(args)
+
+	godotenv.Load()
+	store, err := openStore()
+	if err != nil {
+		log.Fatal("Failed to open storage (is the server still running?): ", err)
+	}
+	defer store.Close()
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			log.Fatal("Failed to open backup file: ", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if err := restore(store, r, *format); err != nil {
+		log.Fatal("Restore failed: ", err)
+	}
+}
+
+// restore loads a backup into store and upgrades it to the current schema.
+// Badger backups are applied on top of the store, so incremental backups
+// can follow the full one; a JSON-lines export needs an empty store.
+func restore(store storage.Store, r io.Reader, format string) error {
+	badgerStore, isBadger := store.(*storage.BadgerStore)
+	if format == "" {
+		format = "jsonl"
+		if isBadger {
+			format = "badger"
+		}
+	}
+
+	switch format {
+	case "badger":
+		if !isBadger {
+			return fmt.Errorf("badger backups need the badger storage backend")
+		}
+		if err := badgerStore.Load(r); err != nil {
+			return err
+		}
+	case "jsonl":
+		imported, err := storage.Import(store, r)
+		if err != nil {
+			return err
+		}
+		log.Printf("Imported %d keys", imported)
+	default:
+		return fmt.Errorf("unknown backup format %q", format)
+	}
+
+	from, to, err := storage.Migrate(store)
+	if err != nil {
+		return err
+	}
+	if from != to {
+		log.Printf("Migrated restored storage from schema version %d to %d", from, to)
+	}
+	return nil
+}
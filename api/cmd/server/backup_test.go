@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func TestBackupRestoreAcrossBackends(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "backup-cli-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	source, err := storage.NewBadgerStore(filepath.Join(tempDir, "badger"))
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer source.Close()
+	storage.Migrate(source)
+	storage.NewNoteStore(source).SaveNote(storage.Note{ID: "welcome", Content: "hello"})
+
+	var badgerBackup, export bytes.Buffer
+	if err := backup(source, &badgerBackup, "", 0); err != nil {
+		t.Fatalf("Failed to back up: %v", err)
+	}
+	if err := backup(source, &export, "jsonl", 0); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	restored, err := storage.NewBadgerStore(filepath.Join(tempDir, "restored"))
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer restored.Close()
+	if err := restore(restored, &badgerBackup, ""); err != nil {
+		t.Fatalf("Failed to restore Badger backup: %v", err)
+	}
+
+	sqlite, err := storage.NewSQLiteStore(filepath.Join(tempDir, "publisher.db"))
+	if err != nil {
+		t.Fatalf("Failed to create SQLiteStore: %v", err)
+	}
+	defer sqlite.Close()
+	if err := restore(sqlite, &export, ""); err != nil {
+		t.Fatalf("Failed to restore export: %v", err)
+	}
+	if err := restore(sqlite, &badgerBackup, "badger"); err == nil {
+		t.Errorf("Expected a Badger backup to be refused by the SQLite backend")
+	}
+
+	for name, store := range map[string]storage.Store{"badger": restored, "sqlite": sqlite} {
+		if note, err := storage.NewNoteStore(store).GetNote("welcome"); err != nil || note.Content != "hello" {
+			t.Errorf("Expected the note restored into %s, got %+v, %v", name, note, err)
+		}
+	}
+}
@@ -1,39 +1,136 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
 	"github.com/lutefd/md-publisher/api/internal/api"
+	"github.com/lutefd/md-publisher/api/internal/scheduler"
 	"github.com/lutefd/md-publisher/api/internal/storage"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found or could not be loaded. Using environment variables.")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backup":
+			runBackup(os.Args[2:])
+			return
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "rekey":
+			runRekey(os.Args[2:])
+			return
+		}
 	}
 
-	if os.Getenv("API_KEY") == "" {
-		log.Println("Warning: API_KEY environment variable not set. Protected endpoints will be accessible without authentication.")
-	}
+	insecure := flag.Bool("insecure", false, "serve protected endpoints without authentication")
+	flag.Parse()
 
-	dataPath := filepath.Join(".", "data")
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found or could not be loaded. Using environment variables.")
+	}
 
-	store, err := storage.NewBadgerStore(dataPath)
+	store, err := openStore()
 	if err != nil {
 		log.Fatal("Failed to initialize storage:", err)
 	}
 	defer store.Close()
 
+	from, to, err := storage.Migrate(store)
+	if err != nil {
+		log.Fatal("Failed to migrate storage:", err)
+	}
+	if from != to {
+		log.Printf("Migrated storage from schema version %d to %d", from, to)
+	}
+
+	badgerStore, isBadger := store.(*storage.BadgerStore)
+	if isBadger {
+		startMaintenance(badgerStore)
+	}
+
 	noteStore := storage.NewNoteStore(store)
+	keyStore := storage.NewKeyStore(store)
+
+	hardExpiry, _ := strconv.ParseBool(os.Getenv("HARD_EXPIRY"))
+	noteStore.SetHardExpiry(hardExpiry)
+	if path := os.Getenv("METADATA_SCHEMA"); path != "" {
+		schema, err := storage.LoadMetadataSchema(path)
+		if err != nil {
+			log.Fatal("Failed to load metadata schema:", err)
+		}
+		noteStore.SetMetadataSchema(schema)
+	}
+
+	rootKey := os.Getenv("API_KEY")
+	if *insecure {
+		log.Println("Warning: running with --insecure. Protected endpoints are accessible without authentication.")
+	} else if rootKey == "" {
+		hasKeys, err := keyStore.HasActiveAPIKeys()
+		if err != nil {
+			log.Fatal("Failed to read API keys:", err)
+		}
+		if !hasKeys {
+			log.Fatal("No credentials configured: set API_KEY, create an API key or start with --insecure")
+		}
+	}
+
+	tokenSecret, err := storage.LoadOrCreateSecret(store, "access")
+	if err != nil {
+		log.Fatal("Failed to load access token secret:", err)
+	}
 
-	apiHandler := api.NewAPI(noteStore)
+	opts := []api.Option{
+		api.WithKeyStore(keyStore),
+		api.WithAuthenticator(api.NewAuthenticator(keyStore, rootKey, *insecure)),
+		api.WithAccessStore(storage.NewAccessStore(store)),
+		api.WithTokenSecret(tokenSecret),
+		api.WithPreviewStore(storage.NewPreviewStore(store)),
+		api.WithTrashStore(noteStore),
+		api.WithRedirectStore(noteStore),
+		api.WithSlugStore(noteStore),
+		api.WithQueryStore(noteStore),
+		api.WithTaskStore(noteStore),
+		api.WithBackupStore(store),
+	}
+	if isBadger {
+		opts = append(opts, api.WithStatsReporter(badgerStore))
+	}
+	if strict, _ := strconv.ParseBool(os.Getenv("STRICT_FRONTMATTER")); strict {
+		opts = append(opts, api.WithStrictFrontmatter(true))
+	}
+	if value := os.Getenv("MAX_BODY_SIZE"); value != "" {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size <= 0 {
+			log.Fatal("Invalid MAX_BODY_SIZE:", value)
+		}
+		opts = append(opts, api.WithMaxBodySize(size))
+	}
+
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		readerAuth, err := newReaderAuth(issuer)
+		if err != nil {
+			log.Fatal("Failed to configure reader authentication:", err)
+		}
+		opts = append(opts, api.WithReaderAuth(readerAuth))
+	}
+
+	apiHandler := api.NewAPI(noteStore, opts...)
 
 	r := chi.NewRouter()
 
@@ -53,8 +150,221 @@ func main() {
 
 	apiHandler.RegisterRoutes(r)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The background loops use the store, so they must stop before the
+	// deferred Close.
+	var workers sync.WaitGroup
+	defer workers.Wait()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		newScheduler(store).Run(ctx)
+	}()
+
+	retention := 30 * 24 * time.Hour
+	if value := os.Getenv("TRASH_RETENTION"); value != "" {
+		retention, err = time.ParseDuration(value)
+		if err != nil || retention < 0 {
+			log.Fatal("Invalid TRASH_RETENTION:", value)
+		}
+	}
+	if retention > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			scheduler.PurgeTrash(ctx, noteStore, retention, time.Hour)
+		}()
+	}
+	if hardExpiry {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			scheduler.DeleteExpiredNotes(ctx, noteStore, time.Hour)
+		}()
+	}
+
+	server := &http.Server{Addr: ":8080", Handler: r}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Println("Failed to shut down server:", err)
+		}
+	}()
+
 	log.Println("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", r); err != nil {
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatal("Failed to start server:", err)
 	}
+	// ListenAndServe returns as soon as shutdown starts; wait for the
+	// requests in flight to finish before the store is closed.
+	<-shutdown
+}
+
+// openStore opens the store configured by STORAGE_BACKEND and
+// STORAGE_PATH, encrypted with the key from encryptionKey.
+func openStore() (storage.Store, error) {
+	backend, dataPath := storageConfig()
+
+	key, err := encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return storage.OpenStore(backend, dataPath)
+	}
+	if backend != storage.BackendBadger && backend != "" {
+		return nil, fmt.Errorf("encryption at rest needs the badger storage backend, not %q", backend)
+	}
+
+	options := []storage.BadgerOption{storage.WithEncryptionKey(key)}
+	if value := os.Getenv("STORAGE_KEY_ROTATION"); value != "" {
+		rotation, err := time.ParseDuration(value)
+		if err != nil || rotation <= 0 {
+			return nil, fmt.Errorf("invalid STORAGE_KEY_ROTATION %q", value)
+		}
+		options = append(options, storage.WithKeyRotation(rotation))
+	}
+	return storage.OpenStore(backend, dataPath, options...)
+}
+
+// storageConfig returns the configured backend and its data path.
+func storageConfig() (backend, dataPath string) {
+	backend = os.Getenv("STORAGE_BACKEND")
+	dataPath = os.Getenv("STORAGE_PATH")
+	if dataPath == "" {
+		dataPath = defaultStoragePath(backend)
+	}
+	return backend, dataPath
+}
+
+// encryptionKey returns the hex encoded key in STORAGE_ENCRYPTION_KEY, or
+// in the file named by STORAGE_ENCRYPTION_KEY_FILE, such as a Docker
+// secret. It returns nil if neither is set.
+func encryptionKey() ([]byte, error) {
+	value := os.Getenv("STORAGE_ENCRYPTION_KEY")
+	if path := os.Getenv("STORAGE_ENCRYPTION_KEY_FILE"); path != "" {
+		if value != "" {
+			return nil, fmt.Errorf("set only one of STORAGE_ENCRYPTION_KEY and STORAGE_ENCRYPTION_KEY_FILE")
+		}
+		return readEncryptionKey(path)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return storage.ParseEncryptionKey(value)
+}
+
+func readEncryptionKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read encryption key: %w", err)
+	}
+	return storage.ParseEncryptionKey(string(data))
+}
+
+// defaultStoragePath places each backend's data under ./data.
+func defaultStoragePath(backend string) string {
+	switch backend {
+	case storage.BackendSQLite:
+		return filepath.Join(".", "data", "publisher.db")
+	case storage.BackendFiles:
+		return filepath.Join(".", "data", "notes")
+	}
+	return filepath.Join(".", "data")
+}
+
+// startMaintenance runs value-log GC every STORAGE_GC_INTERVAL, 10m by
+// default, backing off to STORAGE_GC_MAX_INTERVAL while there is nothing
+// to reclaim. Closing the store stops it.
+func startMaintenance(store *storage.BadgerStore) {
+	opts := storage.MaintenanceOptions{
+		OnGC: func(result storage.GCResult) {
+			if result.Err != nil {
+				log.Println("Value-log GC failed:", result.Err)
+			} else if result.Rewrites > 0 {
+				log.Printf("Value-log GC rewrote %d files and reclaimed %d bytes in %s", result.Rewrites, result.Reclaimed, result.Duration.Round(time.Millisecond))
+			}
+		},
+	}
+	for name, interval := range map[string]*time.Duration{
+		"STORAGE_GC_INTERVAL":     &opts.Interval,
+		"STORAGE_GC_MAX_INTERVAL": &opts.MaxInterval,
+	} {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				log.Fatalf("Invalid %s: %s", name, value)
+			}
+			*interval = parsed
+		}
+	}
+	store.StartMaintenance(opts)
+}
+
+// newScheduler fires schedule events every SCHEDULER_INTERVAL, defaulting
+// to 30s. Events are logged and, if PUBLISH_WEBHOOK_URL is set, posted to
+// it.
+func newScheduler(store storage.Store) *scheduler.Scheduler {
+	interval := 30 * time.Second
+	if value := os.Getenv("SCHEDULER_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Fatal("Invalid SCHEDULER_INTERVAL:", value)
+		}
+		interval = parsed
+	}
+
+	webhookURL := os.Getenv("PUBLISH_WEBHOOK_URL")
+	var webhook func(scheduler.Event)
+	if webhookURL != "" {
+		webhook = scheduler.Webhook(webhookURL)
+	}
+
+	return scheduler.New(storage.NewScheduleStore(store), interval, func(event scheduler.Event) {
+		log.Printf("Note %q %s", event.NoteID, event.Type)
+		if webhook != nil {
+			webhook(event)
+		}
+	})
+}
+
+// newReaderAuth configures OIDC reader authentication from the
+// environment. Rules are read from READER_RULES, defaulting to
+// reader-rules.yaml.
+func newReaderAuth(issuer string) (*api.ReaderAuth, error) {
+	rulesPath := os.Getenv("READER_RULES")
+	if rulesPath == "" {
+		rulesPath = "reader-rules.yaml"
+	}
+	rules, err := api.LoadReaderRules(rulesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionTTL := time.Duration(0)
+	if ttl := os.Getenv("OIDC_SESSION_TTL"); ttl != "" {
+		if sessionTTL, err = time.ParseDuration(ttl); err != nil {
+			return nil, fmt.Errorf("invalid OIDC_SESSION_TTL: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return api.NewReaderAuth(ctx, api.ReaderAuthConfig{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		GroupsClaim:  os.Getenv("OIDC_GROUPS_CLAIM"),
+		SessionTTL:   sessionTTL,
+		Rules:        rules,
+	})
 }
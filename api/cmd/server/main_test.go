@@ -32,7 +32,11 @@ func TestServerSetup(t *testing.T) {
 	defer store.Close()
 
 	noteStore := storage.NewNoteStore(store)
-	apiHandler := api.NewAPI(noteStore)
+	keyStore := storage.NewKeyStore(store)
+	apiHandler := api.NewAPI(noteStore,
+		api.WithKeyStore(keyStore),
+		api.WithAuthenticator(api.NewAuthenticator(keyStore, "root-key", false)),
+	)
 
 	r := chi.NewRouter()
 	apiHandler.RegisterRoutes(r)
@@ -41,6 +45,7 @@ func TestServerSetup(t *testing.T) {
 		name           string
 		method         string
 		path           string
+		apiKey         string
 		expectedStatus int
 	}{
 		{
@@ -59,12 +64,34 @@ func TestServerSetup(t *testing.T) {
 			name:           "POST /publish without body",
 			method:         "POST",
 			path:           "/publish",
+			apiKey:         "root-key",
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "POST /publish without API key",
+			method:         "POST",
+			path:           "/publish",
+			expectedStatus: http.StatusUnauthorized,
+		},
 		{
 			name:           "DELETE /note/nonexistent",
 			method:         "DELETE",
 			path:           "/note/nonexistent",
+			apiKey:         "root-key",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "GET /admin/keys with invalid API key",
+			method:         "GET",
+			path:           "/admin/keys",
+			apiKey:         "wrong-key",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "GET /admin/keys",
+			method:         "GET",
+			path:           "/admin/keys",
+			apiKey:         "root-key",
 			expectedStatus: http.StatusOK,
 		},
 	}
@@ -72,6 +99,9 @@ func TestServerSetup(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.apiKey != "" {
+				req.Header.Set("X-API-Key", tc.apiKey)
+			}
 			w := httptest.NewRecorder()
 			r.ServeHTTP(w, req)
 
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+// runRekey changes the key a stopped server's Badger database is encrypted
+// with. The current key is the configured one unless -old-key-file is
+// given, so an unencrypted database is encrypted by running rekey before
+// configuring a key.
+func runRekey(args []string) {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	oldKeyFile := fs.String("old-key-file", "", "file holding the current hex encoded key, instead of the configured one")
+	newKeyFile := fs.String("new-key-file", "", "file holding the new hex encoded key")
+	decrypt := fs.Bool("decrypt", false, "store the data keys unencrypted instead of using a new key")
+	fs.Parse(args)
+
+	godotenv.Load()
+	if err := rekey(*oldKeyFile, *newKeyFile, *decrypt); err != nil {
+		log.Fatal("Rekey failed: ", err)
+	}
+	log.Println("Database rekeyed; configure the new key before starting the server")
+}
+
+func rekey(oldKeyFile, newKeyFile string, decrypt bool) error {
+	backend, dataPath := storageConfig()
+	if backend != storage.BackendBadger && backend != "" {
+		return fmt.Errorf("encryption at rest needs the badger storage backend, not %q", backend)
+	}
+	if (newKeyFile == "") == !decrypt {
+		return fmt.Errorf("pass either -new-key-file or -decrypt")
+	}
+
+	var oldKey, newKey []byte
+	var err error
+	if oldKeyFile != "" {
+		oldKey, err = readEncryptionKey(oldKeyFile)
+	} else {
+		oldKey, err = encryptionKey()
+	}
+	if err != nil {
+		return err
+	}
+	if newKeyFile != "" {
+		if newKey, err = readEncryptionKey(newKeyFile); err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(dataPath); err != nil {
+		return err
+	}
+	return storage.RekeyBadger(dataPath, oldKey, newKey)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func TestRekeyCommand(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "rekey-cli-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	keyFile := filepath.Join(tempDir, "key")
+	os.WriteFile(keyFile, []byte(strings.Repeat("0f", 32)+"\n"), 0o600)
+
+	t.Setenv("STORAGE_BACKEND", "")
+	t.Setenv("STORAGE_PATH", filepath.Join(tempDir, "data"))
+	t.Setenv("STORAGE_ENCRYPTION_KEY", "")
+	t.Setenv("STORAGE_ENCRYPTION_KEY_FILE", "")
+
+	store, err := openStore()
+	if err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	store.Set("note/welcome", []byte(`{"id":"welcome","content":"hello"}`))
+	store.Close()
+
+	if err := rekey("", "", false); err == nil {
+		t.Errorf("Expected rekey without a new key to fail")
+	}
+	if err := rekey("", keyFile, false); err != nil {
+		t.Fatalf("Failed to encrypt the database: %v", err)
+	}
+
+	if _, err := openStore(); !errors.Is(err, storage.ErrWrongEncryptionKey) {
+		t.Errorf("Expected the encrypted database to need its key, got %v", err)
+	}
+
+	t.Setenv("STORAGE_ENCRYPTION_KEY_FILE", keyFile)
+	store, err = openStore()
+	if err != nil {
+		t.Fatalf("Failed to open with the key file: %v", err)
+	}
+	if value, err := store.Get("note/welcome"); err != nil || !strings.Contains(string(value), "hello") {
+		t.Errorf("Expected the note to survive rekeying, got %q, %v", value, err)
+	}
+	store.Close()
+
+	t.Setenv("STORAGE_ENCRYPTION_KEY", strings.Repeat("0f", 32))
+	if _, err := openStore(); err == nil {
+		t.Errorf("Expected a key in both the environment and a file to be refused")
+	}
+
+	t.Setenv("STORAGE_ENCRYPTION_KEY_FILE", "")
+	t.Setenv("STORAGE_BACKEND", storage.BackendSQLite)
+	if _, err := openStore(); err == nil {
+		t.Errorf("Expected encryption to be refused for the sqlite backend")
+	}
+}
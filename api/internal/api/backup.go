@@ -0,0 +1,76 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+// BadgerBackuper is implemented by stores that can stream Badger backups.
+type BadgerBackuper interface {
+	Backup(w io.Writer, since uint64) (uint64, error)
+	NextSince() uint64
+}
+
+// Backup streams a snapshot of the whole store. format=badger, the default
+// when the store is Badger, uses Badger's backup format; since then makes
+// an incremental backup of the changes after an earlier backup, whose
+// X-Backup-Since header gives the value to pass. format=jsonl streams the
+// portable export, which any backend can import.
+func (api *API) Backup(w http.ResponseWriter, r *http.Request) {
+	backuper, isBadger := api.backupStore.(BadgerBackuper)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "jsonl"
+		if isBadger {
+			format = "badger"
+		}
+	}
+
+	filename := "md-publisher-" + time.Now().UTC().Format("20060102T150405Z")
+
+	switch format {
+	case "badger":
+		if !isBadger {
+			writeError(w, r, http.StatusBadRequest, "Badger backups need the badger storage backend")
+			return
+		}
+
+		var since uint64
+		if value := r.URL.Query().Get("since"); value != "" {
+			var err error
+			if since, err = strconv.ParseUint(value, 10, 64); err != nil {
+				writeError(w, r, http.StatusBadRequest, "since must be the X-Backup-Since of an earlier backup")
+				return
+			}
+		}
+
+		// Taken before the backup, so writes racing with it are repeated
+		// in the next incremental backup rather than missed.
+		next := backuper.NextSince()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.badger"`)
+		w.Header().Set("X-Backup-Since", strconv.FormatUint(next, 10))
+
+		if _, err := backuper.Backup(w, since); err != nil {
+			log.Println("Backup failed:", err)
+		}
+
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.jsonl"`)
+
+		if err := storage.Export(api.backupStore, w); err != nil {
+			log.Println("Export failed:", err)
+		}
+
+	default:
+		writeError(w, r, http.StatusBadRequest, "format must be badger or jsonl")
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func newBackupRouter(store storage.Store) chi.Router {
+	api := NewAPI(storage.NewNoteStore(store),
+		WithAuthenticator(NewAuthenticator(nil, "root-key", false)),
+		WithBackupStore(store),
+	)
+
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+	return r
+}
+
+func TestBackupExport(t *testing.T) {
+	store := storage.NewMemoryStore()
+	storage.NewNoteStore(store).SaveNote(storage.Note{ID: "welcome", Content: "hello"})
+	r := newBackupRouter(store)
+
+	if w := rootKeyRequest(r, "GET", "/admin/backup?format=badger"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected badger backups of a memory store to be refused, got %d", w.Code)
+	}
+
+	w := rootKeyRequest(r, "GET", "/admin/backup")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("Expected a JSON-lines export by default, got %q", contentType)
+	}
+
+	lines := 0
+	for scanner := bufio.NewScanner(w.Body); scanner.Scan(); {
+		lines++
+	}
+	if lines != 3 {
+		t.Errorf("Expected a header, one note and its slug, got %d lines", lines)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/backup", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected backups to require a key, got %d", w.Code)
+	}
+}
+
+func TestBackupBadger(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "api-backup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := storage.NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+	storage.NewNoteStore(store).SaveNote(storage.Note{ID: "welcome", Content: "hello"})
+	r := newBackupRouter(store)
+
+	w := rootKeyRequest(r, "GET", "/admin/backup")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Fatalf("Expected a Badger backup, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	since := w.Header().Get("X-Backup-Since")
+	if since == "" || since == "0" {
+		t.Fatalf("Expected X-Backup-Since, got %q", since)
+	}
+
+	w = rootKeyRequest(r, "GET", "/admin/backup?since="+since)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "hello") {
+		t.Errorf("Expected an incremental backup without the unchanged note, got %d", w.Code)
+	}
+
+	if w := rootKeyRequest(r, "GET", "/admin/backup?since=soon"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid since to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+// errorResponse is the body of every error response.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	// Code is a stable, machine readable name for the error, by default
+	// derived from the status, such as not_found.
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+// writeError writes an error response with the default code for status.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeErrorBody(w, r, status, errorBody{Message: message})
+}
+
+// writeErrorBody writes an error response, filling in the code if body
+// has none and the ID of the request.
+func writeErrorBody(w http.ResponseWriter, r *http.Request, status int, body errorBody) {
+	if body.Code == "" {
+		body.Code = statusCode(status)
+	}
+	body.RequestID = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: body})
+}
+
+// statusCode turns a status into an error code: 404 becomes not_found.
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// storeErrorStatus maps the typed errors of the storage layer to a status.
+// Errors it does not know are internal errors.
+func storeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound), errors.Is(err, storage.ErrNotInTrash):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrNoteExists):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrInvalidVisibility),
+		errors.Is(err, storage.ErrInvalidSchedule),
+		errors.Is(err, storage.ErrInvalidKey),
+		errors.Is(err, storage.ErrUnknownScope),
+		errors.Is(err, storage.ErrInvalidIDPattern):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// writeStoreError reports an error returned by a store. Validation errors
+// are a 422 listing every violation, other errors the client caused are
+// reported with their own message; anything else is logged and
+// reported as an internal error with message, so storage details do not
+// leak to clients.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var validation *storage.ValidationError
+	if errors.As(err, &validation) {
+		writeErrorBody(w, r, http.StatusUnprocessableEntity, errorBody{
+			Code:    "validation_failed",
+			Message: "Note is invalid",
+			Details: map[string]interface{}{"violations": validation.Violations},
+		})
+		return
+	}
+
+	status := storeErrorStatus(err)
+	if status != http.StatusInternalServerError {
+		writeError(w, r, status, capitalize(err.Error()))
+		return
+	}
+
+	log.Printf("[%s] %s: %v", middleware.GetReqID(r.Context()), message, err)
+	writeError(w, r, status, message)
+}
+
+// decodeJSON decodes the JSON body of r into v, reading at most the
+// configured maximum body size.
+func (api *API) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, api.maxBodySize)
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// writeDecodeError reports an error returned by decodeJSON.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeErrorBody(w, r, http.StatusRequestEntityTooLarge, errorBody{
+			Message: fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit),
+			Details: map[string]interface{}{"max_bytes": tooLarge.Limit},
+		})
+		return
+	}
+	writeError(w, r, http.StatusBadRequest, "Invalid request body")
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+// failingNoteStore fails every read with an error the API does not know.
+type failingNoteStore struct {
+	NoteStorer
+}
+
+func (failingNoteStore) GetNote(id string) (storage.Note, error) {
+	return storage.Note{}, errors.New("disk on fire")
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json; charset=utf-8" {
+		t.Errorf("Expected a JSON error, got %q", contentType)
+	}
+	var body errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal error %q: %v", w.Body.String(), err)
+	}
+	return body.Error
+}
+
+func TestErrorResponses(t *testing.T) {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore, WithAuthenticator(NewAuthenticator(nil, "root-key", false)))
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	api.RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/note/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+	body := decodeError(t, w)
+	if body.Code != "not_found" || body.Message != "Note not found" || body.RequestID == "" {
+		t.Errorf("Unexpected error %+v", body)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/note/welcome", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected a missing key to be a 401, got %d", w.Code)
+	}
+	if body := decodeError(t, w); body.Code != "unauthorized" || body.Message != "API key is required" {
+		t.Errorf("Unexpected error %+v", body)
+	}
+
+	w = rootKeyRequest(r, "POST", "/publish")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if body := decodeError(t, w); body.Code != "bad_request" {
+		t.Errorf("Unexpected error %+v", body)
+	}
+}
+
+func TestStoreErrorsAreInternal(t *testing.T) {
+	api := NewAPI(failingNoteStore{})
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/note/welcome", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected an unknown store error to be a 500, got %d", w.Code)
+	}
+	if body := decodeError(t, w); body.Code != "internal_server_error" || body.Message != "Failed to retrieve note" {
+		t.Errorf("Expected the store error to stay internal, got %+v", body)
+	}
+}
+
+func TestStoreErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{storage.ErrNoteNotFound, http.StatusNotFound},
+		{storage.ErrAPIKeyNotFound, http.StatusNotFound},
+		{storage.ErrPreviewLinkNotFound, http.StatusNotFound},
+		{storage.ErrNotInTrash, http.StatusNotFound},
+		{storage.ErrNoteExists, http.StatusConflict},
+		{storage.ErrInvalidSchedule, http.StatusBadRequest},
+		{storage.ErrUnknownScope, http.StatusBadRequest},
+		{errors.New("disk on fire"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if status := storeErrorStatus(tt.err); status != tt.status {
+			t.Errorf("Expected %v to be a %d, got %d", tt.err, tt.status, status)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+// folderIndexName is the name of a note holding the landing page of its
+// folder. A note named after its folder, such as guides/guides, is one
+// too, with or without a .md extension.
+const folderIndexName = "_index"
+
+// folder is a node of the tree of folders implied by path-style note IDs.
+type folder struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+	// Notes counts the notes directly in the folder, Total those in its
+	// subfolders too. Index notes are not counted.
+	Notes int `json:"notes"`
+	Total int `json:"total"`
+	// Index is the ID of the folder's index note, and Title its title.
+	Index    string    `json:"index,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Children []*folder `json:"children,omitempty"`
+
+	notes     []storage.Note
+	indexNote *storage.Note
+}
+
+// folderOf splits a note ID into its folder and name.
+func folderOf(id string) (dir, name string) {
+	i := strings.LastIndex(id, "/")
+	if i < 0 {
+		return "", id
+	}
+	return id[:i], id[i+1:]
+}
+
+// isFolderIndex reports whether the note ID is the index note of its
+// folder.
+func isFolderIndex(id string) bool {
+	dir, name := folderOf(strings.TrimSuffix(id, ".md"))
+	if name == folderIndexName {
+		return true
+	}
+	_, dirName := folderOf(dir)
+	return dir != "" && name == dirName
+}
+
+// folderTree builds the tree of folders holding the notes listed for r.
+// An _index note wins over one named after its folder.
+func (api *API) folderTree(r *http.Request) (*folder, error) {
+	notes, err := api.noteStore.ListNotes()
+	if err != nil {
+		return nil, err
+	}
+
+	root := &folder{}
+	folders := map[string]*folder{"": root}
+	var lookup func(path string) *folder
+	lookup = func(path string) *folder {
+		if f, ok := folders[path]; ok {
+			return f
+		}
+		dir, name := folderOf(path)
+		parent := lookup(dir)
+		f := &folder{Path: path, Name: name}
+		parent.Children = append(parent.Children, f)
+		folders[path] = f
+		return f
+	}
+
+	for _, note := range notes {
+		if !api.listed(r, note) {
+			continue
+		}
+
+		dir, name := folderOf(note.ID)
+		f := lookup(dir)
+		if isFolderIndex(note.ID) {
+			if f.indexNote == nil || strings.TrimSuffix(name, ".md") == folderIndexName {
+				f.indexNote = &note
+			}
+			continue
+		}
+		f.notes = append(f.notes, note)
+	}
+
+	var finish func(f *folder)
+	finish = func(f *folder) {
+		sort.Slice(f.notes, func(i, j int) bool { return f.notes[i].ID < f.notes[j].ID })
+		sort.Slice(f.Children, func(i, j int) bool { return f.Children[i].Name < f.Children[j].Name })
+
+		f.Notes = len(f.notes)
+		f.Total = f.Notes
+		if f.indexNote != nil {
+			f.Index = f.indexNote.ID
+			f.Title, _ = f.indexNote.Metadata["title"].(string)
+		}
+		for _, child := range f.Children {
+			finish(child)
+			f.Total += child.Total
+		}
+	}
+	finish(root)
+
+	return root, nil
+}
+
+// ListFolders returns the tree of folders, with the number of notes in
+// each. Only notes the request may list are counted.
+func (api *API) ListFolders(w http.ResponseWriter, r *http.Request) {
+	root, err := api.folderTree(r)
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to retrieve folders")
+		return
+	}
+
+	render.JSON(w, r, root)
+}
+
+// GetFolder returns a folder with its index note as the landing page, its
+// subfolders and the notes directly in it.
+func (api *API) GetFolder(w http.ResponseWriter, r *http.Request) {
+	path := chi.URLParam(r, "*")
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	path = storage.NormalizeNoteID(path)
+
+	root, err := api.folderTree(r)
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to retrieve folder")
+		return
+	}
+
+	f := root
+	if path != "" {
+		for _, name := range strings.Split(path, "/") {
+			var next *folder
+			for _, child := range f.Children {
+				if child.Name == name {
+					next = child
+					break
+				}
+			}
+			if next == nil {
+				writeError(w, r, http.StatusNotFound, "Folder not found")
+				return
+			}
+			f = next
+		}
+	}
+
+	children := make([]folder, len(f.Children))
+	for i, child := range f.Children {
+		children[i] = *child
+		children[i].Children = nil
+	}
+	notes := make([]map[string]interface{}, len(f.notes))
+	for i, note := range f.notes {
+		notes[i] = noteResponse(note)
+	}
+
+	response := map[string]interface{}{
+		"path":    f.Path,
+		"name":    f.Name,
+		"total":   f.Total,
+		"folders": children,
+		"notes":   notes,
+	}
+	if f.indexNote != nil {
+		response["title"] = f.Title
+		response["index"] = noteResponse(*f.indexNote)
+	}
+	render.JSON(w, r, response)
+}
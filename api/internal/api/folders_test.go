@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func newFolderRouter() chi.Router {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore)
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "about", Content: "about"})
+	noteStore.SaveNote(storage.Note{ID: "docs/_index", Content: "---\ntitle: Documentation\n---\nWelcome"})
+	noteStore.SaveNote(storage.Note{ID: "docs/intro", Content: "intro"})
+	noteStore.SaveNote(storage.Note{ID: "docs/guides/guides", Content: "---\ntitle: Guides\n---\nAll guides"})
+	noteStore.SaveNote(storage.Note{ID: "docs/guides/setup", Content: "setup"})
+	noteStore.SaveNote(storage.Note{ID: "docs/guides/deploy", Content: "deploy"})
+	noteStore.SaveNote(storage.Note{ID: "docs/hidden/draft", Content: "---\ndraft: true\n---\ndraft"})
+	return r
+}
+
+func TestListFolders(t *testing.T) {
+	r := newFolderRouter()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/folders", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var root folder
+	if err := json.Unmarshal(w.Body.Bytes(), &root); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if root.Notes != 1 || root.Total != 4 || len(root.Children) != 1 {
+		t.Fatalf("Unexpected root folder %+v", root)
+	}
+
+	docs := root.Children[0]
+	if docs.Path != "docs" || docs.Notes != 1 || docs.Total != 3 || docs.Index != "docs/_index" || docs.Title != "Documentation" {
+		t.Errorf("Unexpected docs folder %+v", docs)
+	}
+	if len(docs.Children) != 1 {
+		t.Fatalf("Expected folders of hidden notes to be left out, got %+v", docs.Children)
+	}
+
+	guides := docs.Children[0]
+	if guides.Path != "docs/guides" || guides.Notes != 2 || guides.Index != "docs/guides/guides" || guides.Title != "Guides" {
+		t.Errorf("Unexpected guides folder %+v", guides)
+	}
+}
+
+func TestGetFolder(t *testing.T) {
+	r := newFolderRouter()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/folders/docs/guides", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response struct {
+		Path  string       `json:"path"`
+		Title string       `json:"title"`
+		Index storage.Note `json:"index"`
+		Notes []storage.Note
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Path != "docs/guides" || response.Title != "Guides" || response.Index.Content != "All guides" {
+		t.Errorf("Expected the index note as landing page, got %+v", response)
+	}
+	if len(response.Notes) != 2 || response.Notes[0].ID != "docs/guides/deploy" || response.Notes[1].ID != "docs/guides/setup" {
+		t.Errorf("Expected the notes of the folder without its index, got %+v", response.Notes)
+	}
+
+	for _, path := range []string{"/folders/missing", "/folders/docs/hidden", "/folders/docs/intro"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected %s to answer 404, got %d", path, w.Code)
+		}
+	}
+}
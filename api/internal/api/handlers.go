@@ -1,8 +1,12 @@
 package api
 
 import (
-	"encoding/json"
+	"crypto/rand"
+	"errors"
+	"maps"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -18,53 +22,318 @@ type NoteStorer interface {
 }
 
 type API struct {
-	noteStore NoteStorer
+	noteStore     NoteStorer
+	keyStore      KeyStorer
+	accessStore   AccessStorer
+	auth          *Authenticator
+	readerAuth    *ReaderAuth
+	previewStore  PreviewStorer
+	trashStore    TrashStorer
+	redirectStore RedirectStorer
+	slugStore     SlugStorer
+	queryStore    QueryStorer
+	taskStore     TaskStorer
+	backupStore   storage.Store
+	stats         StatsReporter
+	signer        tokenSigner
+	maxBodySize   int64
+	strict        bool
 }
 
-func NewAPI(noteStore NoteStorer) *API {
-	return &API{
-		noteStore: noteStore,
+// DefaultMaxBodySize is the largest request body accepted unless
+// WithMaxBodySize says otherwise.
+const DefaultMaxBodySize = 5 << 20
+
+type Option func(*API)
+
+// WithKeyStore enables the key management endpoints and lets the
+// authenticator accept keys from keyStore.
+func WithKeyStore(keyStore KeyStorer) Option {
+	return func(api *API) {
+		api.keyStore = keyStore
+	}
+}
+
+// WithAuthenticator replaces the default authenticator, which rejects
+// every request to a protected endpoint.
+func WithAuthenticator(auth *Authenticator) Option {
+	return func(api *API) {
+		api.auth = auth
+	}
+}
+
+// WithAccessStore enables password-protected notes and the folder
+// password endpoints.
+func WithAccessStore(accessStore AccessStorer) Option {
+	return func(api *API) {
+		api.accessStore = accessStore
+	}
+}
+
+// WithTokenSecret sets the secret used to sign access tokens. Without it a
+// random secret is generated, so tokens do not survive restarts.
+func WithTokenSecret(secret []byte) Option {
+	return func(api *API) {
+		api.signer = tokenSigner{secret: secret}
+	}
+}
+
+// WithReaderAuth requires readers to sign in before reading notes matched
+// by the reader rules.
+func WithReaderAuth(readerAuth *ReaderAuth) Option {
+	return func(api *API) {
+		api.readerAuth = readerAuth
+	}
+}
+
+// WithPreviewStore enables preview links for draft notes.
+func WithPreviewStore(previewStore PreviewStorer) Option {
+	return func(api *API) {
+		api.previewStore = previewStore
+	}
+}
+
+// WithTrashStore makes unpublishing move notes to the trash, from where
+// they can be restored or purged.
+func WithTrashStore(trashStore TrashStorer) Option {
+	return func(api *API) {
+		api.trashStore = trashStore
+	}
+}
+
+// WithRedirectStore enables moving notes and redirects from the IDs
+// notes were moved from and from their aliases.
+func WithRedirectStore(redirectStore RedirectStorer) Option {
+	return func(api *API) {
+		api.redirectStore = redirectStore
+	}
+}
+
+// WithSlugStore serves notes at their slugs too, redirecting from the
+// slugs they had before.
+func WithSlugStore(slugStore SlugStorer) Option {
+	return func(api *API) {
+		api.slugStore = slugStore
+	}
+}
+
+// WithQueryStore enables the query endpoint.
+func WithQueryStore(queryStore QueryStorer) Option {
+	return func(api *API) {
+		api.queryStore = queryStore
 	}
 }
 
+// WithTaskStore enables the task list endpoint.
+func WithTaskStore(taskStore TaskStorer) Option {
+	return func(api *API) {
+		api.taskStore = taskStore
+	}
+}
+
+// WithBackupStore enables the backup endpoint, streaming snapshots of
+// store.
+func WithBackupStore(store storage.Store) Option {
+	return func(api *API) {
+		api.backupStore = store
+	}
+}
+
+// WithStatsReporter enables the storage stats endpoint.
+func WithStatsReporter(reporter StatsReporter) Option {
+	return func(api *API) {
+		api.stats = reporter
+	}
+}
+
+// WithMaxBodySize sets the largest request body accepted, in bytes.
+// Larger requests are rejected with a 413.
+func WithMaxBodySize(size int64) Option {
+	return func(api *API) {
+		api.maxBodySize = size
+	}
+}
+
+// WithStrictFrontmatter makes publishing a note whose frontmatter does not
+// decode fail by default, rather than publish it with a warning. Requests
+// override it with ?strict=true or ?strict=false.
+func WithStrictFrontmatter(strict bool) Option {
+	return func(api *API) {
+		api.strict = strict
+	}
+}
+
+func NewAPI(noteStore NoteStorer, opts ...Option) *API {
+	api := &API{
+		noteStore:   noteStore,
+		maxBodySize: DefaultMaxBodySize,
+	}
+	for _, opt := range opts {
+		opt(api)
+	}
+	if api.auth == nil {
+		api.auth = NewAuthenticator(api.keyStore, "", false)
+	}
+	if api.signer.secret == nil {
+		api.signer.secret = make([]byte, 32)
+		if _, err := rand.Read(api.signer.secret); err != nil {
+			panic(err)
+		}
+	}
+	return api
+}
+
 func (api *API) RegisterRoutes(r chi.Router) {
+	var notes pathRoutes
+	notes.handle("GET", "", http.HandlerFunc(api.GetNote))
+	notes.handle("DELETE", "", api.auth.Require(storage.ScopeDelete)(http.HandlerFunc(api.UnpublishNote)))
+
 	r.Get("/notes", api.ListNotes)
-	r.Get("/note/{id}", api.GetNote)
+	r.Handle("/note/*", &notes)
+	r.Get("/folders", api.ListFolders)
+	r.Get("/folders/*", api.GetFolder)
 
-	r.Group(func(r chi.Router) {
-		r.Use(APIKeyMiddleware)
-		r.Post("/publish", api.PublishNote)
-		r.Delete("/note/{id}", api.UnpublishNote)
-	})
+	r.With(api.auth.Require(storage.ScopePublish)).Post("/publish", api.PublishNote)
+
+	if api.readerAuth != nil {
+		r.Get("/auth/login", api.Login)
+		r.Get("/auth/callback", api.Callback)
+		r.Post("/auth/logout", api.Logout)
+		r.Get("/auth/me", api.Me)
+	}
+
+	if api.accessStore != nil {
+		notes.handle("POST", "/unlock", http.HandlerFunc(api.UnlockNote))
+
+		r.Route("/admin/folder-passwords", func(r chi.Router) {
+			r.Use(api.auth.Require(storage.ScopeAdmin))
+			r.Put("/", api.SetFolderPassword)
+			r.Delete("/", api.DeleteFolderPassword)
+		})
+	}
+
+	if api.redirectStore != nil {
+		notes.handle("POST", "/move", api.auth.Require(storage.ScopePublish)(http.HandlerFunc(api.MoveNote)))
+	}
+
+	if api.previewStore != nil {
+		requirePublish := api.auth.Require(storage.ScopePublish)
+		notes.handle("POST", "/preview-link", requirePublish(http.HandlerFunc(api.CreatePreviewLink)))
+		notes.handle("GET", "/preview-links", requirePublish(http.HandlerFunc(api.ListPreviewLinks)))
+		notes.handleParam("DELETE", "/preview-link/", "linkID", requirePublish(http.HandlerFunc(api.RevokePreviewLink)))
+	}
+
+	if api.trashStore != nil {
+		var trash pathRoutes
+		trash.handle("POST", "/restore", http.HandlerFunc(api.RestoreNote))
+		trash.handle("DELETE", "", http.HandlerFunc(api.PurgeNote))
+
+		r.Route("/trash", func(r chi.Router) {
+			r.Use(api.auth.Require(storage.ScopeDelete))
+			r.Get("/", api.ListTrash)
+			r.Handle("/*", trash)
+		})
+	}
+
+	if api.queryStore != nil {
+		r.Get("/query", api.Query)
+	}
+
+	if api.taskStore != nil {
+		r.Get("/tasks", api.ListTasks)
+	}
+
+	if api.backupStore != nil {
+		r.With(api.auth.Require(storage.ScopeAdmin)).Get("/admin/backup", api.Backup)
+	}
+
+	if api.stats != nil {
+		r.With(api.auth.Require(storage.ScopeAdmin)).Get("/admin/stats", api.Stats)
+	}
+
+	if api.keyStore != nil {
+		r.Route("/admin/keys", func(r chi.Router) {
+			r.Use(api.auth.Require(storage.ScopeAdmin))
+			r.Post("/", api.CreateKey)
+			r.Get("/", api.ListKeys)
+			r.Delete("/{id}", api.RevokeKey)
+		})
+	}
 }
+
 func (api *API) PublishNote(w http.ResponseWriter, r *http.Request) {
 	var note storage.Note
-	if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := api.decodeJSON(w, r, &note); err != nil {
+		writeDecodeError(w, r, err)
 		return
 	}
 
-	if note.ID == "" {
-		http.Error(w, "Note ID is required", http.StatusBadRequest)
+	// Validation when saving reports a missing ID along with any other
+	// violation.
+	note.ID = storage.NormalizeNoteID(note.ID)
+	if note.ID != "" && !checkIDPermission(w, r, "publish", note.ID) {
 		return
 	}
 
+	strict := api.strict
+	if value := r.URL.Query().Get("strict"); value != "" {
+		var err error
+		if strict, err = strconv.ParseBool(value); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid strict parameter")
+			return
+		}
+	}
+
+	// In strict mode saving rejects frontmatter that does not decode.
+	// Otherwise the frontmatter is dropped, so it is not published as
+	// text, and reported as a warning.
+	warnings := []storage.Violation{}
+	if _, _, err := storage.ParseFrontmatter(note.Content); err != nil && !strict {
+		warnings = append(warnings, storage.FrontmatterViolation(err))
+		note.Content = storage.DropFrontmatter(note.Content)
+	}
+
+	// An alias redirects its ID to the note, so the key must be allowed
+	// to publish there too.
+	extracted := note
+	extracted.Metadata = maps.Clone(note.Metadata)
+	if storage.ExtractFrontmatter(&extracted) == nil {
+		for _, alias := range extracted.Aliases() {
+			if !checkIDPermission(w, r, "publish", alias) {
+				return
+			}
+		}
+	}
+
 	if err := api.noteStore.SaveNote(note); err != nil {
-		http.Error(w, "Failed to store note", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "Failed to store note")
 		return
 	}
 
-	render.JSON(w, r, map[string]string{"status": "Note published successfully"})
+	render.JSON(w, r, map[string]interface{}{
+		"status":   "Note published successfully",
+		"id":       note.ID,
+		"warnings": warnings,
+	})
 }
 func (api *API) UnpublishNote(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
+	id := noteIDParam(r)
 	if id == "" {
-		http.Error(w, "Note ID is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Note ID is required")
+		return
+	}
+
+	if !checkIDPermission(w, r, "delete", id) {
+		return
+	}
+
+	if api.trashStore != nil {
+		api.trashNote(w, r, id)
 		return
 	}
 
 	if err := api.noteStore.DeleteNote(id); err != nil {
-		http.Error(w, "Failed to delete note", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "Failed to delete note")
 		return
 	}
 
@@ -74,56 +343,79 @@ func (api *API) UnpublishNote(w http.ResponseWriter, r *http.Request) {
 func (api *API) ListNotes(w http.ResponseWriter, r *http.Request) {
 	notes, err := api.noteStore.ListNotes()
 	if err != nil {
-		http.Error(w, "Failed to retrieve notes", http.StatusInternalServerError)
+		writeStoreError(w, r, err, "Failed to retrieve notes")
 		return
 	}
 
 	response := make([]map[string]interface{}, 0, len(notes))
 	for _, note := range notes {
-		noteResponse := map[string]interface{}{
-			"id":       note.ID,
-			"content":  note.Content,
-			"metadata": note.Metadata,
+		if !api.listed(r, note) {
+			continue
 		}
 
-		if _, exists := note.Metadata["updated"]; !exists {
-			if noteResponse["metadata"] == nil {
-				noteResponse["metadata"] = make(map[string]interface{})
-			}
-			noteResponse["metadata"].(map[string]interface{})["updated"] = time.Now().Format(time.RFC3339)
-		}
-
-		response = append(response, noteResponse)
+		response = append(response, noteResponse(note))
 	}
 
 	render.JSON(w, r, response)
 }
 
 func (api *API) GetNote(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
+	id := noteIDParam(r)
 	if id == "" {
-		http.Error(w, "Note ID is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Note ID is required")
 		return
 	}
 
 	note, err := api.noteStore.GetNote(id)
-	if err != nil {
-		http.Error(w, "Note not found", http.StatusNotFound)
+	if errors.Is(err, storage.ErrNotFound) && api.slugStore != nil {
+		var handled bool
+		if note, handled, err = api.noteBySlug(w, r, id); handled {
+			return
+		}
+	}
+	if errors.Is(err, storage.ErrNotFound) && api.redirectNote(w, r, id) {
+		return
+	} else if err != nil {
+		writeStoreError(w, r, err, "Failed to retrieve note")
 		return
 	}
 
+	if err := api.checkRead(r, note); err != nil {
+		writeReadError(w, r, err)
+		return
+	}
+
+	render.JSON(w, r, noteResponse(note))
+}
+
+func noteResponse(note storage.Note) map[string]interface{} {
+	metadata := make(map[string]interface{}, len(note.Metadata)+1)
+	for key, value := range note.Metadata {
+		metadata[key] = value
+	}
+	if _, exists := metadata["updated"]; !exists {
+		metadata["updated"] = time.Now().Format(time.RFC3339)
+	}
+
 	response := map[string]interface{}{
 		"id":       note.ID,
 		"content":  note.Content,
-		"metadata": note.Metadata,
+		"metadata": metadata,
+		"toc":      storage.Headings(note.Content),
+		"derived":  note.Derived,
 	}
-
-	if _, exists := note.Metadata["updated"]; !exists {
-		if response["metadata"] == nil {
-			response["metadata"] = make(map[string]interface{})
-		}
-		response["metadata"].(map[string]interface{})["updated"] = time.Now().Format(time.RFC3339)
+	if note.Slug != "" {
+		response["slug"] = note.Slug
 	}
+	return response
+}
 
-	render.JSON(w, r, response)
+// noteIDParam returns the unescaped {id} URL parameter, so IDs containing
+// slashes can be passed as %2F.
+func noteIDParam(r *http.Request) string {
+	id := chi.URLParam(r, "id")
+	if unescaped, err := url.PathUnescape(id); err == nil {
+		return unescaped
+	}
+	return id
 }
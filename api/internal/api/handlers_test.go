@@ -3,59 +3,25 @@ package api
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/lutefd/md-publisher/api/internal/storage"
 )
 
-type MockNoteStore struct {
-	notes map[string]storage.Note
-}
-
-func NewMockNoteStore() *MockNoteStore {
-	return &MockNoteStore{
-		notes: make(map[string]storage.Note),
-	}
-}
-
-func (m *MockNoteStore) SaveNote(note storage.Note) error {
-	storage.ExtractFrontmatter(&note)
-	m.notes[note.ID] = note
-	return nil
-}
-
-func (m *MockNoteStore) GetNote(id string) (storage.Note, error) {
-	note, exists := m.notes[id]
-	if !exists {
-		return storage.Note{}, errors.New("note not found")
-	}
-	return note, nil
-}
-
-func (m *MockNoteStore) DeleteNote(id string) error {
-	if _, exists := m.notes[id]; !exists {
-		return errors.New("note not found")
-	}
-	delete(m.notes, id)
-	return nil
-}
-
-func (m *MockNoteStore) ListNotes() ([]storage.Note, error) {
-	notes := make([]storage.Note, 0, len(m.notes))
-	for _, note := range m.notes {
-		notes = append(notes, note)
-	}
-	return notes, nil
+// newNoteStore returns a note store over an in-memory store, so handlers
+// are tested against the real publishing pipeline.
+func newNoteStore() *storage.NoteStore {
+	return storage.NewNoteStore(storage.NewMemoryStore())
 }
 
 func TestPublishNote(t *testing.T) {
-	mockStore := NewMockNoteStore()
+	noteStore := newNoteStore()
 
-	api := NewAPI(mockStore)
+	api := NewAPI(noteStore)
 	note := storage.Note{
 		ID:      "test-note",
 		Content: "Test content",
@@ -80,7 +46,7 @@ func TestPublishNote(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
 	}
 
-	savedNote, err := mockStore.GetNote(note.ID)
+	savedNote, err := noteStore.GetNote(note.ID)
 	if err != nil {
 		t.Fatalf("Failed to get saved note: %v", err)
 	}
@@ -90,11 +56,11 @@ func TestPublishNote(t *testing.T) {
 }
 
 func TestPublishNoteWithFrontmatter(t *testing.T) {
-	// Create a mock note store
-	mockStore := NewMockNoteStore()
+	// Create a note store
+	noteStore := newNoteStore()
 
-	// Create an API instance with the mock store
-	api := NewAPI(mockStore)
+	// Create an API instance with the note store
+	api := NewAPI(noteStore)
 
 	// Create a test note with frontmatter
 	note := storage.Note{
@@ -133,7 +99,7 @@ tags:
 	}
 
 	// Verify the note was saved with frontmatter extracted
-	savedNote, err := mockStore.GetNote(note.ID)
+	savedNote, err := noteStore.GetNote(note.ID)
 	if err != nil {
 		t.Fatalf("Failed to get saved note: %v", err)
 	}
@@ -151,11 +117,11 @@ tags:
 }
 
 func TestGetNote(t *testing.T) {
-	// Create a mock note store
-	mockStore := NewMockNoteStore()
+	// Create a note store
+	noteStore := newNoteStore()
 
-	// Create an API instance with the mock store
-	api := NewAPI(mockStore)
+	// Create an API instance with the note store
+	api := NewAPI(noteStore)
 
 	// Add a test note to the store
 	testNote := storage.Note{
@@ -165,7 +131,7 @@ func TestGetNote(t *testing.T) {
 			"title": "Test Note",
 		},
 	}
-	mockStore.SaveNote(testNote)
+	noteStore.SaveNote(testNote)
 
 	// Create a new router
 	r := chi.NewRouter()
@@ -202,11 +168,11 @@ func TestGetNote(t *testing.T) {
 }
 
 func TestListNotes(t *testing.T) {
-	// Create a mock note store
-	mockStore := NewMockNoteStore()
+	// Create a note store
+	noteStore := newNoteStore()
 
-	// Create an API instance with the mock store
-	api := NewAPI(mockStore)
+	// Create an API instance with the note store
+	api := NewAPI(noteStore)
 
 	// Add test notes to the store
 	testNotes := []storage.Note{
@@ -227,7 +193,7 @@ func TestListNotes(t *testing.T) {
 	}
 
 	for _, note := range testNotes {
-		mockStore.SaveNote(note)
+		noteStore.SaveNote(note)
 	}
 
 	// Create a request
@@ -258,18 +224,18 @@ func TestListNotes(t *testing.T) {
 }
 
 func TestUnpublishNote(t *testing.T) {
-	// Create a mock note store
-	mockStore := NewMockNoteStore()
+	// Create a note store
+	noteStore := newNoteStore()
 
-	// Create an API instance with the mock store
-	api := NewAPI(mockStore)
+	// Create an API instance with the note store
+	api := NewAPI(noteStore)
 
 	// Add a test note to the store
 	testNote := storage.Note{
 		ID:      "test-note",
 		Content: "Test content",
 	}
-	mockStore.SaveNote(testNote)
+	noteStore.SaveNote(testNote)
 
 	// Create a new router
 	r := chi.NewRouter()
@@ -290,8 +256,182 @@ func TestUnpublishNote(t *testing.T) {
 	}
 
 	// Verify the note was deleted
-	_, err := mockStore.GetNote(testNote.ID)
+	_, err := noteStore.GetNote(testNote.ID)
 	if err == nil {
 		t.Errorf("Expected note to be deleted")
 	}
 }
+
+func TestPublishNoteValidation(t *testing.T) {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore, WithMaxBodySize(1024))
+
+	publish := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/publish", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		api.PublishNote(w, req)
+		return w
+	}
+
+	w := publish(`{"id": "/docs//guide/", "content": "Hello"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if _, err := noteStore.GetNote("docs/guide"); err != nil {
+		t.Errorf("Expected the note under its canonical ID, got %v", err)
+	}
+
+	w = publish(`{"id": "my:note", "content": "---\ntags: [1, 2]\ndate: someday\n---\nHello"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Details struct {
+				Violations []storage.Violation `json:"violations"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	fields := map[string]bool{}
+	for _, violation := range body.Error.Details.Violations {
+		fields[violation.Field] = true
+	}
+	if body.Error.Code != "validation_failed" || !fields["id"] || !fields["metadata.tags"] || !fields["metadata.date"] {
+		t.Errorf("Expected every violation to be listed, got %+v", body.Error)
+	}
+
+	w = publish(`{"id": "large", "content": "` + strings.Repeat("x", 2048) + `"}`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status code %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestGetNoteTableOfContents(t *testing.T) {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore)
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "guide", Content: "# Guide\n\n## Setup\n\n```\n# comment\n```\n\n## Setup\n"})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/note/guide", nil))
+
+	var response struct {
+		TOC []storage.Heading `json:"toc"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	anchors := make([]string, len(response.TOC))
+	for i, heading := range response.TOC {
+		anchors[i] = heading.Anchor
+	}
+	if strings.Join(anchors, ",") != "guide,setup,setup-1" {
+		t.Errorf("Expected the headings outside code blocks with unique anchors, got %+v", response.TOC)
+	}
+}
+
+func TestListNotesDerivedMetadata(t *testing.T) {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore)
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "post", Content: "# Post\n\n![](cover.png)\n\nA *short* post."})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/notes", nil))
+
+	var response []struct {
+		Derived storage.DerivedMetadata `json:"derived"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	want := storage.DerivedMetadata{WordCount: 4, CharCount: 18, ReadingTime: 1, Excerpt: "A short post.", Image: "cover.png"}
+	if len(response) != 1 || response[0].Derived != want {
+		t.Errorf("Expected derived metadata %+v in the listing, got %+v", want, response)
+	}
+}
+
+func TestPublishNoteFrontmatterErrors(t *testing.T) {
+	noteStore := newNoteStore()
+
+	publish := func(api *API, query, content string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(storage.Note{ID: "broken", Content: content})
+		req := httptest.NewRequest("POST", "/publish"+query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		api.PublishNote(w, req)
+		return w
+	}
+	broken := "---\ntitle: Broken\n  tags: a\n---\nHello"
+
+	var lenient struct {
+		Warnings []storage.Violation `json:"warnings"`
+	}
+	w := publish(NewAPI(noteStore), "", broken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &lenient); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(lenient.Warnings) != 1 || lenient.Warnings[0].Field != "frontmatter" || lenient.Warnings[0].Line != 3 {
+		t.Errorf("Expected a frontmatter warning on line 3, got %+v", lenient.Warnings)
+	}
+	if note, _ := noteStore.GetNote("broken"); note.Content != "Hello" {
+		t.Errorf("Expected the broken frontmatter to be dropped, got %q", note.Content)
+	}
+
+	var strict struct {
+		Error struct {
+			Details struct {
+				Violations []storage.Violation `json:"violations"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	for _, tt := range []struct {
+		api   *API
+		query string
+	}{
+		{NewAPI(noteStore), "?strict=true"},
+		{NewAPI(noteStore, WithStrictFrontmatter(true)), ""},
+	} {
+		w = publish(tt.api, tt.query, "+++\ntitle = \"x\"\ndraft = maybe\n+++\nHello")
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &strict); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		want := storage.Violation{Field: "frontmatter", Message: `toml: expected value but found "maybe" instead`, Line: 3, Column: 9}
+		if violations := strict.Error.Details.Violations; len(violations) != 1 || violations[0] != want {
+			t.Errorf("Expected %+v, got %+v", want, violations)
+		}
+	}
+
+	for _, content := range []string{"+++\nrating = inf\n+++\nHello", "---\nratio: .nan\n---\nHello"} {
+		w = publish(NewAPI(noteStore), "?strict=true", content)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status code %d for a non-finite number, got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		}
+	}
+
+	w = publish(NewAPI(noteStore, WithStrictFrontmatter(true)), "?strict=false", broken)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected ?strict=false to override the default, got %d", w.Code)
+	}
+	w = publish(NewAPI(noteStore), "?strict=sometimes", broken)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for an invalid strict parameter, got %d", http.StatusBadRequest, w.Code)
+	}
+}
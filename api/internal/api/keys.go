@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type createKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	Prefixes  []string   `json:"prefixes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (api *API) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req createKeyRequest
+	if err := api.decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, r, http.StatusBadRequest, "Key name is required")
+		return
+	}
+
+	key, token, err := api.keyStore.CreateAPIKey(req.Name, req.Scopes, req.Prefixes, req.ExpiresAt)
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to create key")
+		return
+	}
+
+	key.Hash = ""
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, map[string]interface{}{
+		"key":   key,
+		"token": token,
+	})
+}
+
+func (api *API) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := api.keyStore.ListAPIKeys()
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to retrieve keys")
+		return
+	}
+
+	for i := range keys {
+		keys[i].Hash = ""
+	}
+
+	render.JSON(w, r, keys)
+}
+
+func (api *API) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "Key ID is required")
+		return
+	}
+
+	if err := api.keyStore.RevokeAPIKey(id); err != nil {
+		writeStoreError(w, r, err, "Failed to revoke key")
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"status": "Key revoked successfully"})
+}
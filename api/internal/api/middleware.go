@@ -1,34 +1,126 @@
 package api
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
 	"net/http"
-	"os"
-	"strings"
+	"time"
 
-	"github.com/go-chi/render"
+	"github.com/lutefd/md-publisher/api/internal/storage"
 )
 
-func APIKeyMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apiKey := os.Getenv("API_KEY")
-		if apiKey == "" {
-			next.ServeHTTP(w, r)
-			return
-		}
+type contextKey string
 
-		requestKey := r.Header.Get("X-API-Key")
-		if requestKey == "" {
-			render.JSON(w, r, map[string]string{"error": "API key is required"})
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
+const apiKeyContextKey contextKey = "apiKey"
+
+type KeyStorer interface {
+	CreateAPIKey(name string, scopes, prefixes []string, expiresAt *time.Time) (storage.APIKey, string, error)
+	ListAPIKeys() ([]storage.APIKey, error)
+	RevokeAPIKey(id string) error
+	VerifyAPIKey(token string) (storage.APIKey, error)
+}
+
+// Authenticator resolves the X-API-Key header of protected requests. Keys
+// come from the key store, plus an optional root key taken from the
+// API_KEY environment variable. In insecure mode every request is treated
+// as coming from an admin key.
+type Authenticator struct {
+	keys     KeyStorer
+	rootHash [sha256.Size]byte
+	hasRoot  bool
+	insecure bool
+}
 
-		if !strings.EqualFold(requestKey, apiKey) {
-			render.JSON(w, r, map[string]string{"error": "Invalid API key"})
-			w.WriteHeader(http.StatusUnauthorized)
-			return
+func NewAuthenticator(keys KeyStorer, rootKey string, insecure bool) *Authenticator {
+	a := &Authenticator{keys: keys, insecure: insecure}
+	if rootKey != "" {
+		a.rootHash = sha256.Sum256([]byte(rootKey))
+		a.hasRoot = true
+	}
+	return a
+}
+
+var rootAPIKey = storage.APIKey{ID: "root", Name: "API_KEY", Scopes: []string{storage.ScopeAdmin}}
+
+func (a *Authenticator) authenticate(token string) (storage.APIKey, bool) {
+	if a.hasRoot {
+		hash := sha256.Sum256([]byte(token))
+		if subtle.ConstantTimeCompare(hash[:], a.rootHash[:]) == 1 {
+			return rootAPIKey, true
 		}
+	}
+
+	if a.keys == nil {
+		return storage.APIKey{}, false
+	}
+
+	key, err := a.keys.VerifyAPIKey(token)
+	if err != nil {
+		return storage.APIKey{}, false
+	}
+	return key, true
+}
+
+// Require only lets through requests carrying a key with the given scope.
+// The key is stored in the request context, see APIKeyFromContext.
+func (a *Authenticator) Require(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if a.insecure {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, rootAPIKey)))
+				return
+			}
+
+			requestKey := r.Header.Get("X-API-Key")
+			if requestKey == "" {
+				writeError(w, r, http.StatusUnauthorized, "API key is required")
+				return
+			}
+
+			key, ok := a.authenticate(requestKey)
+			if !ok {
+				writeError(w, r, http.StatusUnauthorized, "Invalid API key")
+				return
+			}
+
+			if !key.HasScope(scope) {
+				writeErrorBody(w, r, http.StatusForbidden, errorBody{
+					Message: "API key lacks the " + scope + " scope",
+					Details: map[string]interface{}{"scope": scope},
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey, key)))
+		})
+	}
+}
+
+// APIKeyFromContext returns the key that authenticated the request, if any.
+func APIKeyFromContext(ctx context.Context) (storage.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey).(storage.APIKey)
+	return key, ok
+}
+
+// checkIDPermission reports whether the key that authenticated r may
+// modify the note id. If not, it writes a 403 naming the denied ID and the
+// patterns the key is restricted to. Requests that did not go through the
+// authenticator are allowed. Handlers touching several notes must check
+// every ID before changing any of them.
+func checkIDPermission(w http.ResponseWriter, r *http.Request, action, id string) bool {
+	key, ok := APIKeyFromContext(r.Context())
+	if !ok || key.AllowsID(id) {
+		return true
+	}
 
-		next.ServeHTTP(w, r)
+	writeErrorBody(w, r, http.StatusForbidden, errorBody{
+		Message: fmt.Sprintf("API key %q may not %s %q", key.Name, action, id),
+		Details: map[string]interface{}{
+			"id":      id,
+			"allowed": key.Prefixes,
+		},
 	})
+	return false
 }
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+type MockKeyStore struct {
+	keys   map[string]storage.APIKey
+	tokens map[string]string
+}
+
+func NewMockKeyStore() *MockKeyStore {
+	return &MockKeyStore{
+		keys:   make(map[string]storage.APIKey),
+		tokens: make(map[string]string),
+	}
+}
+
+func (m *MockKeyStore) CreateAPIKey(name string, scopes, prefixes []string, expiresAt *time.Time) (storage.APIKey, string, error) {
+	key := storage.APIKey{
+		ID:        name,
+		Name:      name,
+		Hash:      "hash",
+		Scopes:    scopes,
+		Prefixes:  prefixes,
+		ExpiresAt: expiresAt,
+	}
+	token := "token-" + name
+	m.keys[key.ID] = key
+	m.tokens[token] = key.ID
+	return key, token, nil
+}
+
+func (m *MockKeyStore) ListAPIKeys() ([]storage.APIKey, error) {
+	keys := make([]storage.APIKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *MockKeyStore) RevokeAPIKey(id string) error {
+	key, exists := m.keys[id]
+	if !exists {
+		return storage.ErrInvalidAPIKey
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	m.keys[id] = key
+	return nil
+}
+
+func (m *MockKeyStore) VerifyAPIKey(token string) (storage.APIKey, error) {
+	key, exists := m.keys[m.tokens[token]]
+	if !exists || !key.Active(time.Now()) {
+		return storage.APIKey{}, storage.ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+func newAuthRouter(keyStore *MockKeyStore, rootKey string, insecure bool) (chi.Router, *storage.NoteStore) {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore,
+		WithKeyStore(keyStore),
+		WithAuthenticator(NewAuthenticator(keyStore, rootKey, insecure)),
+	)
+
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+	return r, noteStore
+}
+
+func publishRequest(t *testing.T, id, apiKey string) *http.Request {
+	t.Helper()
+
+	body, err := json.Marshal(storage.Note{ID: id, Content: "content"})
+	if err != nil {
+		t.Fatalf("Failed to marshal note: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/publish", bytes.NewBuffer(body))
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	return req
+}
+
+func TestAuthenticatorScopes(t *testing.T) {
+	keyStore := NewMockKeyStore()
+	_, publishToken, _ := keyStore.CreateAPIKey("publisher", []string{storage.ScopePublish}, []string{"docs/**"}, nil)
+	_, deleteToken, _ := keyStore.CreateAPIKey("deleter", []string{storage.ScopeDelete}, nil, nil)
+	_, revokedToken, _ := keyStore.CreateAPIKey("revoked", []string{storage.ScopeAdmin}, nil, nil)
+	keyStore.RevokeAPIKey("revoked")
+
+	r, _ := newAuthRouter(keyStore, "root-key", false)
+
+	testCases := []struct {
+		name           string
+		id             string
+		apiKey         string
+		expectedStatus int
+	}{
+		{"missing key", "docs/a", "", http.StatusUnauthorized},
+		{"unknown key", "docs/a", "nope", http.StatusUnauthorized},
+		{"revoked key", "docs/a", revokedToken, http.StatusUnauthorized},
+		{"missing scope", "docs/a", deleteToken, http.StatusForbidden},
+		{"outside prefix", "blog/a", publishToken, http.StatusForbidden},
+		{"nested path", "docs/guides/a", publishToken, http.StatusOK},
+		{"allowed", "docs/a", publishToken, http.StatusOK},
+		{"root key", "blog/a", "root-key", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, publishRequest(t, tc.id, tc.apiKey))
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tc.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorInsecure(t *testing.T) {
+	r, noteStore := newAuthRouter(NewMockKeyStore(), "", true)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, publishRequest(t, "open", ""))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if _, err := noteStore.GetNote("open"); err != nil {
+		t.Errorf("Expected note to be published in insecure mode: %v", err)
+	}
+}
+
+func TestKeyManagement(t *testing.T) {
+	keyStore := NewMockKeyStore()
+	r, _ := newAuthRouter(keyStore, "root-key", false)
+
+	body := bytes.NewBufferString(`{"name":"ci","scopes":["publish"]}`)
+	req := httptest.NewRequest("POST", "/admin/keys", body)
+	req.Header.Set("X-API-Key", "root-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var created struct {
+		Key   storage.APIKey `json:"key"`
+		Token string         `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if created.Token == "" || created.Key.Hash != "" {
+		t.Errorf("Expected a token and no hash, got %+v", created)
+	}
+
+	req = httptest.NewRequest("GET", "/admin/keys", nil)
+	req.Header.Set("X-API-Key", created.Token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected non-admin key to get %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	req = httptest.NewRequest("DELETE", "/admin/keys/ci", nil)
+	req.Header.Set("X-API-Key", "root-key")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if _, err := keyStore.VerifyAPIKey(created.Token); err == nil {
+		t.Errorf("Expected revoked key to be rejected")
+	}
+}
+
+func TestCheckIDPermissionBody(t *testing.T) {
+	keyStore := NewMockKeyStore()
+	_, designToken, _ := keyStore.CreateAPIKey("design", []string{storage.ScopeAdmin}, []string{"design/**"}, nil)
+	r, noteStore := newAuthRouter(keyStore, "", false)
+	noteStore.SaveNote(storage.Note{ID: "engineering/runbook", Content: "keep me"})
+
+	req := httptest.NewRequest("DELETE", "/note/engineering%2Frunbook", nil)
+	req.Header.Set("X-API-Key", designToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Details struct {
+				ID      string   `json:"id"`
+				Allowed []string `json:"allowed"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	details := body.Error.Details
+	if body.Error.Code != "forbidden" || details.ID != "engineering/runbook" || len(details.Allowed) != 1 || details.Allowed[0] != "design/**" {
+		t.Errorf("Unexpected 403 body %+v", body)
+	}
+	if _, err := noteStore.GetNote("engineering/runbook"); err != nil {
+		t.Errorf("Expected note to survive a denied delete")
+	}
+}
+
+func TestPublishChecksAliasPermission(t *testing.T) {
+	keyStore := NewMockKeyStore()
+	_, designToken, _ := keyStore.CreateAPIKey("design", []string{storage.ScopePublish}, []string{"design/**"}, nil)
+	r, noteStore := newAuthRouter(keyStore, "", false)
+
+	publish := func(content string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(storage.Note{ID: "design/x", Content: content})
+		req := httptest.NewRequest("POST", "/publish", bytes.NewBuffer(body))
+		req.Header.Set("X-API-Key", designToken)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := publish("---\naliases: [engineering/runbook]\n---\nbody"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected an alias outside the key's patterns to be refused, got %d", w.Code)
+	}
+	if _, err := noteStore.GetRedirect("engineering/runbook"); err == nil {
+		t.Errorf("Expected the refused alias not to be recorded")
+	}
+	if w := publish("---\naliases: [design/old]\n---\nbody"); w.Code != http.StatusOK {
+		t.Errorf("Expected an alias within the key's patterns to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/render"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+type RedirectStorer interface {
+	MoveNote(from, to string) ([]string, error)
+	Backlinks(id string) ([]string, error)
+	GetRedirect(from string) (storage.Redirect, error)
+}
+
+type moveRequest struct {
+	To string `json:"to"`
+}
+
+// MoveNote renames a note. The key must be allowed to change the note at
+// both IDs, and every note whose links to it are rewritten.
+func (api *API) MoveNote(w http.ResponseWriter, r *http.Request) {
+	id := noteIDParam(r)
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "Note ID is required")
+		return
+	}
+
+	var req moveRequest
+	if err := api.decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	to := storage.NormalizeNoteID(req.To)
+
+	if !checkIDPermission(w, r, "move", id) {
+		return
+	}
+	if to != "" && !checkIDPermission(w, r, "publish", to) {
+		return
+	}
+
+	backlinks, err := api.redirectStore.Backlinks(id)
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to find links to the note")
+		return
+	}
+	for _, linking := range backlinks {
+		if linking != id && !checkIDPermission(w, r, "update links in", linking) {
+			return
+		}
+	}
+
+	updated, err := api.redirectStore.MoveNote(id, to)
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to move note")
+		return
+	}
+
+	render.JSON(w, r, map[string]interface{}{
+		"status":  "Note moved successfully",
+		"from":    id,
+		"to":      to,
+		"updated": updated,
+	})
+}
+
+// redirectNote answers a request for a note ID that does not exist with a
+// 301 to the note it was moved to or is an alias of. It reports whether
+// there was such a note.
+func (api *API) redirectNote(w http.ResponseWriter, r *http.Request, id string) bool {
+	if api.redirectStore == nil {
+		return false
+	}
+
+	redirect, err := api.redirectStore.GetRedirect(id)
+	if err != nil {
+		return false
+	}
+	note, err := api.noteStore.GetNote(redirect.To)
+	if err != nil {
+		return false
+	}
+	return api.redirectTo(w, r, note)
+}
+
+// redirectTo answers with a 301 to the canonical URL of note, keeping the
+// query. Only notes the reader may read, or unlock with a password, are
+// redirected to, so the IDs of others do not leak; it reports whether it
+// redirected.
+func (api *API) redirectTo(w http.ResponseWriter, r *http.Request, note storage.Note) bool {
+	if err := api.checkRead(r, note); err != nil && !errors.Is(err, errNoteLocked) {
+		return false
+	}
+
+	location := url.URL{Path: "/note/" + canonicalPath(note), RawQuery: r.URL.RawQuery}
+	http.Redirect(w, r, location.String(), http.StatusMovedPermanently)
+	return true
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func postMove(r chi.Router, id, to, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/note/"+id+"/move", bytes.NewBufferString(`{"to":"`+to+`"}`))
+	req.Header.Set("X-API-Key", apiKey)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMoveNote(t *testing.T) {
+	keyStore := NewMockKeyStore()
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore,
+		WithKeyStore(keyStore),
+		WithAuthenticator(NewAuthenticator(keyStore, "root-key", false)),
+		WithRedirectStore(noteStore),
+	)
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "docs/setup", Content: "---\naliases: [install]\n---\nsetup"})
+	noteStore.SaveNote(storage.Note{ID: "docs/index", Content: "See [[docs/setup]]."})
+	noteStore.SaveNote(storage.Note{ID: "blog/post", Content: "See [[docs/setup]]."})
+	noteStore.SaveNote(storage.Note{ID: "docs/draft", Content: "---\ndraft: true\naliases: [wip]\n---\ndraft"})
+
+	_, docsKey, _ := keyStore.CreateAPIKey("docs", []string{storage.ScopePublish}, []string{"docs/**"}, nil)
+	if w := postMove(r, "docs/setup", "docs/install", docsKey); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a key that may not update every linking note to be refused, got %d", w.Code)
+	}
+
+	w := postMove(r, "docs/setup", "guides/setup", "root-key")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected move to succeed, got %d: %s", w.Code, w.Body)
+	}
+	var moved struct {
+		To      string   `json:"to"`
+		Updated []string `json:"updated"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &moved); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if moved.To != "guides/setup" || len(moved.Updated) != 2 {
+		t.Errorf("Unexpected move response %+v", moved)
+	}
+
+	for _, path := range []string{"/note/docs/setup", "/note/install"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path+"?preview=x", nil))
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/note/guides/setup?preview=x" {
+			t.Errorf("Expected %s to redirect to the note, got %d to %q", path, w.Code, w.Header().Get("Location"))
+		}
+	}
+	if code := getWithCookie(r, "/note/wip", nil); code != http.StatusNotFound {
+		t.Errorf("Expected the alias of a draft not to redirect, got %d", code)
+	}
+
+	if w := postMove(r, "guides/setup", "docs/index", "root-key"); w.Code != http.StatusConflict {
+		t.Errorf("Expected moving onto a note to answer 409, got %d", w.Code)
+	}
+	if w := postMove(r, "guides/setup", "../up", "root-key"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected moving to an invalid ID to answer 422, got %d", w.Code)
+	}
+}
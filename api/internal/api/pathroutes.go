@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// pathRoute is an action on a resource addressed by a path-style ID, such
+// as POST /note/<id>/unlock. Since IDs contain slashes, chi cannot match
+// them with a {id} parameter; pathRoutes matches the action suffix
+// instead.
+type pathRoute struct {
+	method string
+	// suffix follows the ID, "" for the resource itself. A suffix ending
+	// in "/" is followed by one more segment, stored in the URL
+	// parameter param.
+	suffix  string
+	param   string
+	handler http.Handler
+}
+
+// pathRoutes serves a "/prefix/*" route, splitting the wildcard into the
+// ID and the action of the first route it matches. The ID is stored in the
+// "id" URL parameter, where noteIDParam finds it. IDs may be sent with
+// their slashes escaped too.
+type pathRoutes []pathRoute
+
+func (routes *pathRoutes) handle(method, suffix string, handler http.Handler) {
+	*routes = append(*routes, pathRoute{method: method, suffix: suffix, handler: handler})
+}
+
+func (routes *pathRoutes) handleParam(method, suffix, param string, handler http.Handler) {
+	*routes = append(*routes, pathRoute{method: method, suffix: suffix, param: param, handler: handler})
+}
+
+func (routes pathRoutes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rctx := chi.RouteContext(r.Context())
+	path := chi.URLParam(r, "*")
+
+	allowed := false
+	// Routes with a suffix are tried first, so an action is never read
+	// as part of the ID.
+	for _, withSuffix := range []bool{true, false} {
+		for _, route := range routes {
+			if (route.suffix != "") != withSuffix {
+				continue
+			}
+
+			id, param, ok := route.match(path)
+			if !ok || id == "" {
+				continue
+			}
+			if route.method != r.Method {
+				allowed = true
+				continue
+			}
+
+			rctx.URLParams.Add("id", id)
+			if route.param != "" {
+				rctx.URLParams.Add(route.param, param)
+			}
+			route.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	if allowed {
+		writeError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeError(w, r, http.StatusNotFound, "Not found")
+}
+
+// match splits path into the ID and the parameter of the route.
+func (route pathRoute) match(path string) (id, param string, ok bool) {
+	if route.param == "" {
+		id, ok = strings.CutSuffix(path, route.suffix)
+		return id, "", ok
+	}
+
+	i := strings.LastIndex(path, route.suffix)
+	if i < 0 {
+		return "", "", false
+	}
+	id, param = path[:i], path[i+len(route.suffix):]
+	if param == "" || strings.Contains(param, "/") {
+		return "", "", false
+	}
+	return id, param, true
+}
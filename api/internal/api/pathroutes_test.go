@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func TestPathStyleNoteRoutes(t *testing.T) {
+	store := storage.NewMemoryStore()
+	noteStore := storage.NewNoteStore(store)
+	api := NewAPI(noteStore,
+		WithAuthenticator(NewAuthenticator(nil, "root-key", false)),
+		WithPreviewStore(storage.NewPreviewStore(store)),
+		WithTrashStore(noteStore),
+	)
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "docs/guides/setup", Content: "setup"})
+	noteStore.SaveNote(storage.Note{ID: "docs/draft", Content: "---\ndraft: true\n---\ndraft"})
+
+	for _, path := range []string{"/note/docs/guides/setup", "/note/docs%2Fguides%2Fsetup"} {
+		if code := getWithCookie(r, path, nil); code != http.StatusOK {
+			t.Errorf("Expected %s to answer 200, got %d", path, code)
+		}
+	}
+	if code := getWithCookie(r, "/note/docs/guides", nil); code != http.StatusNotFound {
+		t.Errorf("Expected a folder to be no note, got %d", code)
+	}
+	if w := rootKeyRequest(r, "PUT", "/note/docs/guides/setup"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected an unknown method to answer 405, got %d", w.Code)
+	}
+
+	if code, _ := createPreviewLink(t, r, "docs/draft"); code != http.StatusCreated {
+		t.Fatalf("Expected preview link for a nested draft, got %d", code)
+	}
+	w := rootKeyRequest(r, "GET", "/note/docs/draft/preview-links")
+	var links []storage.PreviewLink
+	if err := json.Unmarshal(w.Body.Bytes(), &links); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(links) != 1 || links[0].NoteID != "docs/draft" {
+		t.Fatalf("Expected one preview link for docs/draft, got %+v", links)
+	}
+	if w := rootKeyRequest(r, "DELETE", "/note/docs/draft/preview-link/"+links[0].ID); w.Code != http.StatusOK {
+		t.Errorf("Expected revoking the link to succeed, got %d", w.Code)
+	}
+
+	if w := rootKeyRequest(r, "DELETE", "/note/docs/guides/setup"); w.Code != http.StatusOK {
+		t.Fatalf("Expected unpublish to succeed, got %d", w.Code)
+	}
+	if w := rootKeyRequest(r, "POST", "/trash/docs/guides/setup/restore"); w.Code != http.StatusOK {
+		t.Fatalf("Expected restore to succeed, got %d", w.Code)
+	}
+	rootKeyRequest(r, "DELETE", "/note/docs/guides/setup")
+	if w := rootKeyRequest(r, "DELETE", "/trash/docs/guides/setup"); w.Code != http.StatusOK {
+		t.Errorf("Expected purge to succeed, got %d", w.Code)
+	}
+}
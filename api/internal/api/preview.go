@@ -0,0 +1,167 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+const (
+	purposePreview = "preview"
+
+	defaultPreviewTTL = 7 * 24 * time.Hour
+	maxPreviewTTL     = 30 * 24 * time.Hour
+)
+
+var (
+	errNoteHidden   = errors.New("note not found")
+	errPreviewStale = errors.New("preview link is for an older revision")
+)
+
+type PreviewStorer interface {
+	CreatePreviewLink(noteID, revision string, expiresAt time.Time) (storage.PreviewLink, error)
+	GetPreviewLink(id string) (storage.PreviewLink, error)
+	ListPreviewLinks(noteID string) ([]storage.PreviewLink, error)
+	RevokePreviewLink(id string) error
+}
+
+// checkPreview lets a hidden note through only for a preview token whose
+// link is still active and points at the note's current revision.
+func (api *API) checkPreview(r *http.Request, note storage.Note) error {
+	token := r.URL.Query().Get("preview")
+	if token == "" || api.previewStore == nil {
+		return errNoteHidden
+	}
+
+	claims, err := api.signer.verify(token, purposePreview, time.Now())
+	if err != nil {
+		return errNoteHidden
+	}
+	link, err := api.previewStore.GetPreviewLink(claims.Subject)
+	if err != nil || link.NoteID != note.ID {
+		return errNoteHidden
+	}
+	if link.Revision != storage.Revision(note) {
+		return errPreviewStale
+	}
+	return nil
+}
+
+type previewLinkRequest struct {
+	ExpiresIn string `json:"expires_in"`
+}
+
+// CreatePreviewLink mints a signed link to the current revision of a
+// draft note or of a note scheduled for later.
+func (api *API) CreatePreviewLink(w http.ResponseWriter, r *http.Request) {
+	id := noteIDParam(r)
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "Note ID is required")
+		return
+	}
+
+	if !checkIDPermission(w, r, "preview", id) {
+		return
+	}
+
+	var req previewLinkRequest
+	if err := api.decodeJSON(w, r, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	ttl := defaultPreviewTTL
+	if req.ExpiresIn != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 || ttl > maxPreviewTTL {
+			writeError(w, r, http.StatusBadRequest, "expires_in must be a positive duration of at most 720h")
+			return
+		}
+	}
+
+	note, err := api.noteStore.GetNote(id)
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to retrieve note")
+		return
+	}
+	if !note.IsDraft() && !note.Pending(time.Now()) {
+		writeError(w, r, http.StatusBadRequest, "Only drafts and scheduled notes have preview links")
+		return
+	}
+
+	link, err := api.previewStore.CreatePreviewLink(id, storage.Revision(note), time.Now().Add(ttl))
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to create preview link")
+		return
+	}
+
+	token, err := api.signer.sign(tokenClaims{Purpose: purposePreview, Subject: link.ID, ExpiresAt: link.ExpiresAt.Unix()})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to create preview link")
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, map[string]interface{}{
+		"link":  link,
+		"token": token,
+		"url":   "/note/" + url.PathEscape(id) + "?preview=" + url.QueryEscape(token),
+	})
+}
+
+func (api *API) ListPreviewLinks(w http.ResponseWriter, r *http.Request) {
+	id := noteIDParam(r)
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "Note ID is required")
+		return
+	}
+
+	if !checkIDPermission(w, r, "preview", id) {
+		return
+	}
+
+	links, err := api.previewStore.ListPreviewLinks(id)
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to retrieve preview links")
+		return
+	}
+
+	render.JSON(w, r, links)
+}
+
+func (api *API) RevokePreviewLink(w http.ResponseWriter, r *http.Request) {
+	id := noteIDParam(r)
+	linkID := chi.URLParam(r, "linkID")
+	if id == "" || linkID == "" {
+		writeError(w, r, http.StatusBadRequest, "Note ID and link ID are required")
+		return
+	}
+
+	if !checkIDPermission(w, r, "preview", id) {
+		return
+	}
+
+	link, err := api.previewStore.GetPreviewLink(linkID)
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to retrieve preview link")
+		return
+	}
+	if link.NoteID != id {
+		writeError(w, r, http.StatusNotFound, "Preview link not found")
+		return
+	}
+
+	if err := api.previewStore.RevokePreviewLink(linkID); err != nil {
+		writeStoreError(w, r, err, "Failed to revoke preview link")
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"status": "Preview link revoked successfully"})
+}
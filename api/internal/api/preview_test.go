@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+type MockPreviewStore struct {
+	links map[string]storage.PreviewLink
+}
+
+func NewMockPreviewStore() *MockPreviewStore {
+	return &MockPreviewStore{links: make(map[string]storage.PreviewLink)}
+}
+
+func (m *MockPreviewStore) CreatePreviewLink(noteID, revision string, expiresAt time.Time) (storage.PreviewLink, error) {
+	link := storage.PreviewLink{
+		ID:        noteID + "-" + revision,
+		NoteID:    noteID,
+		Revision:  revision,
+		ExpiresAt: expiresAt,
+	}
+	m.links[link.ID] = link
+	return link, nil
+}
+
+func (m *MockPreviewStore) GetPreviewLink(id string) (storage.PreviewLink, error) {
+	link, exists := m.links[id]
+	if !exists || time.Now().After(link.ExpiresAt) {
+		return storage.PreviewLink{}, storage.ErrPreviewLinkNotFound
+	}
+	return link, nil
+}
+
+func (m *MockPreviewStore) ListPreviewLinks(noteID string) ([]storage.PreviewLink, error) {
+	links := []storage.PreviewLink{}
+	for _, link := range m.links {
+		if link.NoteID == noteID {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func (m *MockPreviewStore) RevokePreviewLink(id string) error {
+	delete(m.links, id)
+	return nil
+}
+
+func newPreviewRouter() (chi.Router, *storage.NoteStore) {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore,
+		WithAuthenticator(NewAuthenticator(nil, "root-key", false)),
+		WithPreviewStore(NewMockPreviewStore()),
+	)
+
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "published", Content: "live"})
+	noteStore.SaveNote(storage.Note{ID: "draft", Content: "---\ndraft: true\n---\nwork in progress"})
+	return r, noteStore
+}
+
+func createPreviewLink(t *testing.T, r chi.Router, id string) (int, string) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/note/"+id+"/preview-link", bytes.NewBufferString(`{"expires_in":"1h"}`))
+	req.Header.Set("X-API-Key", "root-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var created struct {
+		URL string `json:"url"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	return w.Code, created.URL
+}
+
+func TestDraftsAreHidden(t *testing.T) {
+	r, _ := newPreviewRouter()
+
+	if ids := listedIDs(t, r, nil); len(ids) != 1 || ids[0] != "published" {
+		t.Errorf("Expected only the published note to be listed, got %v", ids)
+	}
+	if code := getWithCookie(r, "/note/draft", nil); code != http.StatusNotFound {
+		t.Errorf("Expected draft to be hidden, got %d", code)
+	}
+	if code := getWithCookie(r, "/note/draft?preview=forged", nil); code != http.StatusNotFound {
+		t.Errorf("Expected forged preview token to be rejected, got %d", code)
+	}
+}
+
+func TestPreviewLink(t *testing.T) {
+	r, noteStore := newPreviewRouter()
+
+	if code, _ := createPreviewLink(t, r, "published"); code != http.StatusBadRequest {
+		t.Errorf("Expected preview links to require a draft, got %d", code)
+	}
+
+	code, previewURL := createPreviewLink(t, r, "draft")
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, code)
+	}
+
+	if code := getWithCookie(r, previewURL, nil); code != http.StatusOK {
+		t.Errorf("Expected preview link to grant access, got %d", code)
+	}
+
+	noteStore.SaveNote(storage.Note{ID: "draft", Content: "---\ndraft: true\n---\nrewritten"})
+	if code := getWithCookie(r, previewURL, nil); code != http.StatusGone {
+		t.Errorf("Expected preview link to stop working for a new revision, got %d", code)
+	}
+
+	_, previewURL = createPreviewLink(t, r, "draft")
+	req := httptest.NewRequest("GET", "/note/draft/preview-links", nil)
+	req.Header.Set("X-API-Key", "root-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var links []storage.PreviewLink
+	if err := json.Unmarshal(w.Body.Bytes(), &links); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("Expected two preview links, got %v", links)
+	}
+
+	for _, link := range links {
+		req = httptest.NewRequest("DELETE", "/note/draft/preview-link/"+link.ID, nil)
+		req.Header.Set("X-API-Key", "root-key")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	}
+
+	if code := getWithCookie(r, previewURL, nil); code != http.StatusNotFound {
+		t.Errorf("Expected revoked preview link to be rejected, got %d", code)
+	}
+}
+
+func TestScheduledNotes(t *testing.T) {
+	r, noteStore := newPreviewRouter()
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	noteStore.SaveNote(storage.Note{ID: "launch", Content: "---\npublish_at: " + future + "\n---\nsoon"})
+	noteStore.SaveNote(storage.Note{ID: "retired", Content: "---\nexpire_at: " + past + "\n---\nold"})
+	noteStore.SaveNote(storage.Note{ID: "live", Content: "---\npublish_at: " + past + "\nexpire_at: " + future + "\n---\nnow"})
+
+	if ids := listedIDs(t, r, nil); len(ids) != 2 {
+		t.Errorf("Expected only published and live notes to be listed, got %v", ids)
+	}
+	if code := getWithCookie(r, "/note/launch", nil); code != http.StatusNotFound {
+		t.Errorf("Expected pending note to be hidden, got %d", code)
+	}
+	if code := getWithCookie(r, "/note/retired", nil); code != http.StatusNotFound {
+		t.Errorf("Expected expired note to be hidden, got %d", code)
+	}
+	if code := getWithCookie(r, "/note/live", nil); code != http.StatusOK {
+		t.Errorf("Expected live note to be readable, got %d", code)
+	}
+
+	code, previewURL := createPreviewLink(t, r, "launch")
+	if code != http.StatusCreated {
+		t.Fatalf("Expected pending note to accept preview links, got %d", code)
+	}
+	if code := getWithCookie(r, previewURL, nil); code != http.StatusOK {
+		t.Errorf("Expected preview link to grant access to the pending note, got %d", code)
+	}
+}
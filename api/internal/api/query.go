@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+type QueryStorer interface {
+	Query(q *storage.Query, keep func(storage.Note) bool) ([]storage.QueryResult, error)
+}
+
+// queryRow is a note matched by a query. Values holds the values of the
+// columns of a TABLE query, in order.
+type queryRow struct {
+	ID     string        `json:"id"`
+	Slug   string        `json:"slug,omitempty"`
+	Title  string        `json:"title"`
+	Values []interface{} `json:"values,omitempty"`
+}
+
+// Query runs the query in ?q= over the notes listed for the request, see
+// storage.Query for the language. A query that does not parse is a 400
+// with the column of the error.
+func (api *API) Query(w http.ResponseWriter, r *http.Request) {
+	q, err := storage.ParseQuery(r.URL.Query().Get("q"))
+	var queryErr *storage.QueryError
+	if errors.As(err, &queryErr) {
+		writeErrorBody(w, r, http.StatusBadRequest, errorBody{
+			Code:    "invalid_query",
+			Message: "Invalid query: " + queryErr.Message,
+			Details: map[string]interface{}{"column": queryErr.Column},
+		})
+		return
+	}
+
+	results, err := api.queryStore.Query(q, func(note storage.Note) bool { return api.listed(r, note) })
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to run query")
+		return
+	}
+
+	rows := make([]queryRow, 0, len(results))
+	for _, result := range results {
+		rows = append(rows, queryRow{ID: result.Note.ID, Slug: result.Note.Slug, Title: noteTitle(result.Note), Values: result.Values})
+	}
+
+	response := map[string]interface{}{"type": "list", "rows": rows}
+	if q.Table {
+		response["type"] = "table"
+		response["columns"] = q.Fields
+	}
+
+	render.JSON(w, r, response)
+}
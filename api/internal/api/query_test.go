@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func TestQuery(t *testing.T) {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore, WithQueryStore(noteStore))
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "work/launch", Content: "---\ntitle: Launch\nstatus: done\n---\n#project"})
+	noteStore.SaveNote(storage.Note{ID: "work/roadmap", Content: "---\nstatus: open\n---\n#project"})
+	noteStore.SaveNote(storage.Note{ID: "work/secret", Content: "---\nstatus: open\nvisibility: unlisted\n---\n#project"})
+
+	query := func(q string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/query?q="+url.QueryEscape(q), nil))
+		return w
+	}
+
+	w := query(`TABLE status AS "Status" FROM #project SORT status`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Type    string               `json:"type"`
+		Columns []storage.QueryField `json:"columns"`
+		Rows    []queryRow           `json:"rows"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Type != "table" || len(response.Columns) != 1 || response.Columns[0].Label != "Status" {
+		t.Errorf("Unexpected table %+v", response)
+	}
+	if len(response.Rows) != 2 {
+		t.Fatalf("Expected the unlisted note to be left out, got %+v", response.Rows)
+	}
+	if row := response.Rows[0]; row.ID != "work/launch" || row.Title != "Launch" || row.Slug != "work/launch" || len(row.Values) != 1 || row.Values[0] != "done" {
+		t.Errorf("Unexpected row %+v", row)
+	}
+	if row := response.Rows[1]; row.ID != "work/roadmap" || row.Title != "work/roadmap" {
+		t.Errorf("Unexpected row %+v", row)
+	}
+
+	w = query(`LIST WHERE status = "nothing"`)
+	if w.Code != http.StatusOK || w.Body.String() != "{\"rows\":[],\"type\":\"list\"}\n" {
+		t.Errorf("Expected an empty list, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = query(`LIST WHERE status =`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected a bad request, got %d", w.Code)
+	}
+	var errResponse errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errResponse); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if errResponse.Error.Code != "invalid_query" || errResponse.Error.Details["column"] != float64(20) {
+		t.Errorf("Unexpected error %+v", errResponse.Error)
+	}
+}
@@ -0,0 +1,386 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-chi/render"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	sessionCookieName    = "mdp_session"
+	loginStateCookieName = "mdp_login"
+	loginStateTTL        = 10 * time.Minute
+	defaultSessionTTL    = 12 * time.Hour
+)
+
+var (
+	errLoginRequired = errors.New("login required")
+	errReaderDenied  = errors.New("reader may not access note")
+)
+
+// ReaderRule restricts the notes under Folder, or tagged with Tag, to
+// signed-in readers. A rule with neither applies to every note. When
+// EmailDomains, Emails or Groups are set the reader must match one of
+// them; otherwise any signed-in reader is allowed.
+type ReaderRule struct {
+	Folder       string   `yaml:"folder"`
+	Tag          string   `yaml:"tag"`
+	EmailDomains []string `yaml:"email_domains"`
+	Emails       []string `yaml:"emails"`
+	Groups       []string `yaml:"groups"`
+}
+
+func (rule ReaderRule) applies(note storage.Note) bool {
+	if rule.Folder != "" {
+		folder := strings.Trim(rule.Folder, "/")
+		if note.ID != folder && !strings.HasPrefix(note.ID, folder+"/") {
+			return false
+		}
+	}
+	if rule.Tag != "" && !hasTag(note, rule.Tag) {
+		return false
+	}
+	return true
+}
+
+func (rule ReaderRule) allows(session tokenClaims) bool {
+	if len(rule.EmailDomains) == 0 && len(rule.Emails) == 0 && len(rule.Groups) == 0 {
+		return true
+	}
+
+	email := strings.ToLower(session.Email)
+	for _, allowed := range rule.Emails {
+		if email != "" && strings.EqualFold(allowed, email) {
+			return true
+		}
+	}
+	for _, domain := range rule.EmailDomains {
+		if email != "" && strings.HasSuffix(email, "@"+strings.ToLower(strings.TrimPrefix(domain, "@"))) {
+			return true
+		}
+	}
+	for _, group := range rule.Groups {
+		for _, readerGroup := range session.Groups {
+			if group == readerGroup {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasTag reports whether the note has tag or a tag nested under it, in
+// its tags field or its content, the way the tag index matches tags.
+func hasTag(note storage.Note, tag string) bool {
+	tag = storage.NormalizeTag(tag)
+	return slices.ContainsFunc(note.Tags(), func(t string) bool {
+		return t == tag || strings.HasPrefix(t, tag+"/")
+	})
+}
+
+type ReaderAuthConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// GroupsClaim names the ID token claim holding the reader's groups.
+	// Defaults to "groups".
+	GroupsClaim string
+	SessionTTL  time.Duration
+	Rules       []ReaderRule
+}
+
+// ReaderAuth signs readers in with an OpenID Connect provider, using the
+// authorization code flow with PKCE, and decides which notes they can read.
+type ReaderAuth struct {
+	oauth       oauth2.Config
+	verifier    *oidc.IDTokenVerifier
+	groupsClaim string
+	sessionTTL  time.Duration
+	rules       []ReaderRule
+}
+
+// NewReaderAuth discovers the provider's endpoints from its issuer URL.
+func NewReaderAuth(ctx context.Context, cfg ReaderAuthConfig) (*ReaderAuth, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("discover OIDC provider: %w", err)
+	}
+
+	ra := &ReaderAuth{
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier:    provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		groupsClaim: cfg.GroupsClaim,
+		sessionTTL:  cfg.SessionTTL,
+		rules:       cfg.Rules,
+	}
+	if ra.groupsClaim == "" {
+		ra.groupsClaim = "groups"
+	}
+	if ra.sessionTTL == 0 {
+		ra.sessionTTL = defaultSessionTTL
+	}
+	return ra, nil
+}
+
+// LoadReaderRules reads reader rules from a YAML file with a top-level
+// "rules" list.
+func LoadReaderRules(path string) ([]ReaderRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Rules []ReaderRule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse reader rules: %w", err)
+	}
+	return file.Rules, nil
+}
+
+// checkReader applies the reader rules matching the note: the reader must
+// be signed in and satisfy every one of them.
+func (api *API) checkReader(r *http.Request, note storage.Note) error {
+	if api.readerAuth == nil {
+		return nil
+	}
+
+	var matching []ReaderRule
+	for _, rule := range api.readerAuth.rules {
+		if rule.applies(note) {
+			matching = append(matching, rule)
+		}
+	}
+	if len(matching) == 0 {
+		return nil
+	}
+
+	session, ok := api.session(r)
+	if !ok {
+		return errLoginRequired
+	}
+	for _, rule := range matching {
+		if !rule.allows(session) {
+			return errReaderDenied
+		}
+	}
+	return nil
+}
+
+func (api *API) session(r *http.Request) (tokenClaims, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return tokenClaims{}, false
+	}
+	claims, err := api.signer.verify(cookie.Value, purposeSession, time.Now())
+	return claims, err == nil
+}
+
+func writeReaderError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errLoginRequired) {
+		writeErrorBody(w, r, http.StatusUnauthorized, errorBody{
+			Code:    "login_required",
+			Message: "Login required",
+			Details: map[string]interface{}{
+				"login_url": "/auth/login?return_to=" + url.QueryEscape(r.URL.RequestURI()),
+			},
+		})
+		return
+	}
+
+	writeError(w, r, http.StatusForbidden, "You do not have access to this note")
+}
+
+// Login redirects the reader to the provider. The state, nonce and PKCE
+// verifier travel in a short-lived signed cookie.
+func (api *API) Login(w http.ResponseWriter, r *http.Request) {
+	state, err := randomToken()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	expiresAt := time.Now().Add(loginStateTTL)
+	cookie, err := api.signer.sign(tokenClaims{
+		Purpose:   purposeLoginState,
+		Subject:   state,
+		ExpiresAt: expiresAt.Unix(),
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ReturnTo:  safeReturnTo(r.URL.Query().Get("return_to")),
+	})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	setCookie(w, r, loginStateCookieName, cookie, expiresAt)
+
+	http.Redirect(w, r, api.readerAuth.oauth.AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(verifier),
+	), http.StatusFound)
+}
+
+// Callback completes the login, storing the reader's email and groups in
+// a signed session cookie.
+func (api *API) Callback(w http.ResponseWriter, r *http.Request) {
+	stateCookie, err := r.Cookie(loginStateCookieName)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "Login state missing or expired")
+		return
+	}
+	login, err := api.signer.verify(stateCookie.Value, purposeLoginState, time.Now())
+	if err != nil || login.Subject != r.URL.Query().Get("state") {
+		writeError(w, r, http.StatusBadRequest, "Invalid login state")
+		return
+	}
+	setCookie(w, r, loginStateCookieName, "", time.Unix(0, 0))
+
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		writeError(w, r, http.StatusUnauthorized, "Login failed: "+errParam)
+		return
+	}
+
+	oauthToken, err := api.readerAuth.oauth.Exchange(r.Context(), r.URL.Query().Get("code"), oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		writeError(w, r, http.StatusUnauthorized, "Failed to exchange authorization code")
+		return
+	}
+
+	rawIDToken, ok := oauthToken.Extra("id_token").(string)
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, "Provider did not return an ID token")
+		return
+	}
+	idToken, err := api.readerAuth.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil || idToken.Nonce != login.Nonce {
+		writeError(w, r, http.StatusUnauthorized, "Invalid ID token")
+		return
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		writeError(w, r, http.StatusUnauthorized, "Invalid ID token claims")
+		return
+	}
+
+	session := tokenClaims{
+		Purpose:   purposeSession,
+		Subject:   idToken.Subject,
+		ExpiresAt: time.Now().Add(api.readerAuth.sessionTTL).Unix(),
+		Groups:    stringsClaim(claims[api.readerAuth.groupsClaim]),
+	}
+	if verified, ok := claims["email_verified"].(bool); !ok || verified {
+		session.Email, _ = claims["email"].(string)
+	}
+
+	value, err := api.signer.sign(session)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+	setCookie(w, r, sessionCookieName, value, time.Unix(session.ExpiresAt, 0))
+
+	returnTo := login.ReturnTo
+	if returnTo == "" {
+		returnTo = "/"
+	}
+	http.Redirect(w, r, returnTo, http.StatusFound)
+}
+
+func (api *API) Logout(w http.ResponseWriter, r *http.Request) {
+	setCookie(w, r, sessionCookieName, "", time.Unix(0, 0))
+	render.JSON(w, r, map[string]string{"status": "Logged out successfully"})
+}
+
+// Me describes the signed-in reader.
+func (api *API) Me(w http.ResponseWriter, r *http.Request) {
+	session, ok := api.session(r)
+	if !ok {
+		writeReaderError(w, r, errLoginRequired)
+		return
+	}
+
+	render.JSON(w, r, map[string]interface{}{
+		"subject": session.Subject,
+		"email":   session.Email,
+		"groups":  session.Groups,
+	})
+}
+
+func setCookie(w http.ResponseWriter, r *http.Request, name, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// safeReturnTo only keeps local paths, so the login flow cannot be used as
+// an open redirect.
+func safeReturnTo(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.HasPrefix(returnTo, "/\\") {
+		return ""
+	}
+	return returnTo
+}
+
+func stringsClaim(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
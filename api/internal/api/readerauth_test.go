@@ -0,0 +1,300 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+// mockOIDCProvider is a minimal OpenID Connect provider: discovery, JWKS
+// and a token endpoint that checks the PKCE verifier of codes issued by
+// authorize.
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	codes  map[string]mockAuthCode
+}
+
+type mockAuthCode struct {
+	challenge string
+	claims    map[string]interface{}
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	p := &mockOIDCProvider{key: key, codes: make(map[string]mockAuthCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		code, exists := p.codes[r.PostForm.Get("code")]
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !exists || base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     p.sign(t, code.claims),
+		})
+	})
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize plays the user signing in at the provider and returns the
+// authorization code for the given authorize URL.
+func (p *mockOIDCProvider) authorize(t *testing.T, authorizeURL string, claims map[string]interface{}) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authorizeURL)
+	if err != nil {
+		t.Fatalf("Failed to parse authorize URL: %v", err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("Expected a PKCE S256 challenge, got %q", query.Get("code_challenge_method"))
+	}
+
+	claims["iss"] = p.server.URL
+	claims["aud"] = query.Get("client_id")
+	claims["nonce"] = query.Get("nonce")
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+
+	code = "code-" + query.Get("state")
+	p.codes[code] = mockAuthCode{challenge: query.Get("code_challenge"), claims: claims}
+	return code, query.Get("state")
+}
+
+func (p *mockOIDCProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sum := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("Failed to sign ID token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newReaderAuthRouter(t *testing.T, provider *mockOIDCProvider) chi.Router {
+	t.Helper()
+
+	readerAuth, err := NewReaderAuth(context.Background(), ReaderAuthConfig{
+		Issuer:       provider.server.URL,
+		ClientID:     "publisher",
+		ClientSecret: "secret",
+		RedirectURL:  "http://publisher.test/auth/callback",
+		Rules: []ReaderRule{
+			{Folder: "internal", EmailDomains: []string{"example.com"}},
+			{Tag: "leadership", Groups: []string{"leads"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create reader auth: %v", err)
+	}
+
+	noteStore := newNoteStore()
+	noteStore.SaveNote(storage.Note{ID: "welcome", Content: "public"})
+	noteStore.SaveNote(storage.Note{ID: "internal/handbook", Content: "---\naliases: [handbook]\n---\ninternal"})
+	noteStore.SaveNote(storage.Note{ID: "internal/plans", Content: "---\ntags: [leadership]\n---\nplans"})
+	noteStore.SaveNote(storage.Note{ID: "offsite", Content: "---\ntags: [Leadership]\n---\noffsite"})
+	noteStore.SaveNote(storage.Note{ID: "retro", Content: "Notes from the #leadership/2024 retro."})
+
+	r := chi.NewRouter()
+	NewAPI(noteStore, WithReaderAuth(readerAuth), WithRedirectStore(noteStore)).RegisterRoutes(r)
+	return r
+}
+
+// login runs the authorization code flow and returns the session cookie.
+func login(t *testing.T, r chi.Router, provider *mockOIDCProvider, claims map[string]interface{}) *http.Cookie {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/auth/login?return_to=/notes", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected login to redirect, got %d", w.Code)
+	}
+	loginCookies := w.Result().Cookies()
+
+	code, state := provider.authorize(t, w.Header().Get("Location"), claims)
+
+	req := httptest.NewRequest("GET", "/auth/callback?code="+url.QueryEscape(code)+"&state="+url.QueryEscape(state), nil)
+	for _, cookie := range loginCookies {
+		req.AddCookie(cookie)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected callback to redirect, got %d: %s", w.Code, w.Body.String())
+	}
+	if location := w.Header().Get("Location"); location != "/notes" {
+		t.Errorf("Expected redirect back to the listing, got %q", location)
+	}
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == sessionCookieName && cookie.Value != "" {
+			return cookie
+		}
+	}
+	t.Fatalf("Expected a session cookie")
+	return nil
+}
+
+func getWithCookie(r chi.Router, path string, cookie *http.Cookie) int {
+	req := httptest.NewRequest("GET", path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestReaderAuthFlow(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	r := newReaderAuthRouter(t, provider)
+
+	if code := getWithCookie(r, "/note/welcome", nil); code != http.StatusOK {
+		t.Errorf("Expected public note to be readable, got %d", code)
+	}
+	if code := getWithCookie(r, "/note/internal%2Fhandbook", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected internal note to require login, got %d", code)
+	}
+	if code := getWithCookie(r, "/note/handbook", nil); code != http.StatusNotFound {
+		t.Errorf("Expected the alias of an internal note not to redirect anonymous readers, got %d", code)
+	}
+	if ids := listedIDs(t, r, nil); len(ids) != 1 {
+		t.Errorf("Expected only the public note to be listed, got %v", ids)
+	}
+
+	employee := login(t, r, provider, map[string]interface{}{
+		"sub":            "alice",
+		"email":          "alice@example.com",
+		"email_verified": true,
+	})
+	if code := getWithCookie(r, "/note/internal%2Fhandbook", employee); code != http.StatusOK {
+		t.Errorf("Expected employee to read internal note, got %d", code)
+	}
+	if code := getWithCookie(r, "/note/handbook", employee); code != http.StatusMovedPermanently {
+		t.Errorf("Expected the alias to redirect the employee, got %d", code)
+	}
+	if code := getWithCookie(r, "/note/internal%2Fplans", employee); code != http.StatusForbidden {
+		t.Errorf("Expected leadership note to need the leads group, got %d", code)
+	}
+	for _, path := range []string{"/note/offsite", "/note/retro"} {
+		if code := getWithCookie(r, path, employee); code != http.StatusForbidden {
+			t.Errorf("Expected %s, tagged leadership another way, to need the leads group, got %d", path, code)
+		}
+	}
+
+	lead := login(t, r, provider, map[string]interface{}{
+		"sub":            "bob",
+		"email":          "bob@example.com",
+		"email_verified": true,
+		"groups":         []string{"leads"},
+	})
+	if code := getWithCookie(r, "/note/internal%2Fplans", lead); code != http.StatusOK {
+		t.Errorf("Expected lead to read leadership note, got %d", code)
+	}
+	if ids := listedIDs(t, r, []*http.Cookie{lead}); len(ids) != 5 {
+		t.Errorf("Expected lead to see every note, got %v", ids)
+	}
+
+	outsider := login(t, r, provider, map[string]interface{}{
+		"sub":            "eve",
+		"email":          "eve@example.com",
+		"email_verified": false,
+	})
+	if code := getWithCookie(r, "/note/internal%2Fhandbook", outsider); code != http.StatusForbidden {
+		t.Errorf("Expected unverified email to be denied, got %d", code)
+	}
+}
+
+func TestReaderAuthCallbackRejectsBadState(t *testing.T) {
+	provider := newMockOIDCProvider(t)
+	r := newReaderAuthRouter(t, provider)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/auth/login", nil))
+	loginCookies := w.Result().Cookies()
+	code, _ := provider.authorize(t, w.Header().Get("Location"), map[string]interface{}{"sub": "mallory"})
+
+	req := httptest.NewRequest("GET", "/auth/callback?code="+url.QueryEscape(code)+"&state=forged", nil)
+	for _, cookie := range loginCookies {
+		req.AddCookie(cookie)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected forged state to be rejected, got %d", w.Code)
+	}
+}
+
+func TestSafeReturnTo(t *testing.T) {
+	tests := map[string]string{
+		"/note/a":            "/note/a",
+		"https://evil.test/": "",
+		"//evil.test/":       "",
+		"/\\evil.test/":      "",
+		"":                   "",
+	}
+	for in, want := range tests {
+		if got := safeReturnTo(in); got != want {
+			t.Errorf("safeReturnTo(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
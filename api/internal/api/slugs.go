@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+type SlugStorer interface {
+	GetNoteBySlug(slug string) (storage.Note, error)
+}
+
+// canonicalPath is the path of the note's URL below /note/: its slug, or
+// its ID for notes published before slugs.
+func canonicalPath(note storage.Note) string {
+	if note.Slug != "" {
+		return note.Slug
+	}
+	return note.ID
+}
+
+// noteTitle is the title of a note, else its ID.
+func noteTitle(note storage.Note) string {
+	if title, ok := note.Metadata["title"].(string); ok && title != "" {
+		return title
+	}
+	return note.ID
+}
+
+// noteBySlug looks up the note with the slug for GetNote. A note that has
+// moved on from the slug is redirected to; handled reports whether a
+// response was written.
+func (api *API) noteBySlug(w http.ResponseWriter, r *http.Request, slug string) (note storage.Note, handled bool, err error) {
+	note, err = api.slugStore.GetNoteBySlug(slug)
+	if err != nil || note.Slug == slug {
+		return note, false, err
+	}
+	if api.redirectTo(w, r, note) {
+		return note, true, nil
+	}
+	return note, false, storage.ErrNoteNotFound
+}
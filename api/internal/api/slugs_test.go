@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func TestNoteSlugs(t *testing.T) {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore, WithSlugStore(noteStore), WithRedirectStore(noteStore))
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "Projects/Q3 Plan.md", Content: "plan"})
+	noteStore.SaveNote(storage.Note{ID: "Drafts/Idea.md", Content: "---\ndraft: true\n---\nidea"})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/note/projects/q3-plan", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the note at its slug, got %d", w.Code)
+	}
+	var note storage.Note
+	if err := json.Unmarshal(w.Body.Bytes(), &note); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if note.ID != "Projects/Q3 Plan.md" || note.Slug != "projects/q3-plan" {
+		t.Errorf("Unexpected note %+v", note)
+	}
+
+	if code := getWithCookie(r, "/note/Projects/Q3%20Plan.md", nil); code != http.StatusOK {
+		t.Errorf("Expected the note at its ID too, got %d", code)
+	}
+
+	noteStore.SaveNote(storage.Note{ID: "Projects/Q3 Plan.md", Content: "---\nslug: plans/q3\n---\nplan"})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/note/projects/q3-plan", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/note/plans/q3" {
+		t.Errorf("Expected the old slug to redirect, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	if code := getWithCookie(r, "/note/drafts/idea", nil); code != http.StatusNotFound {
+		t.Errorf("Expected a draft to stay hidden at its slug, got %d", code)
+	}
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+// StatsReporter is implemented by stores that report their size on disk.
+type StatsReporter interface {
+	Stats() storage.BadgerStats
+}
+
+// Stats returns the database size, its LSM levels and the value-log GC
+// runs since the server started.
+func (api *API) Stats(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, api.stats.Stats())
+}
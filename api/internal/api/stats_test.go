@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func TestStats(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "api-stats-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := storage.NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+	store.RunValueLogGC(0.5)
+
+	api := NewAPI(storage.NewNoteStore(store),
+		WithAuthenticator(NewAuthenticator(nil, "root-key", false)),
+		WithStatsReporter(store),
+	)
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	w := rootKeyRequest(r, "GET", "/admin/stats")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var stats storage.BadgerStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if len(stats.Levels) == 0 || stats.GC.Runs != 1 {
+		t.Errorf("Expected LSM levels and the GC run, got %+v", stats)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/render"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+type TaskStorer interface {
+	ListTasks(filter storage.TaskFilter, keep func(storage.Note) bool) ([]storage.NoteTask, error)
+}
+
+// taskNote is the note a task is in.
+type taskNote struct {
+	ID    string `json:"id"`
+	Slug  string `json:"slug,omitempty"`
+	Title string `json:"title"`
+}
+
+type taskResponse struct {
+	storage.Task
+	Note taskNote `json:"note"`
+	// Link is the URL of the note on the site, at the task's heading.
+	Link string `json:"link"`
+}
+
+// ListTasks returns the task items of the notes listed for the request,
+// filtered by ?status= and ?tag=, with links to the notes and headings
+// they are in.
+func (api *API) ListTasks(w http.ResponseWriter, r *http.Request) {
+	var filter storage.TaskFilter
+	if status := r.URL.Query().Get("status"); status != "" && status != "all" {
+		var err error
+		if filter.Status, err = storage.ParseTaskStatus(status); err != nil {
+			writeErrorBody(w, r, http.StatusBadRequest, errorBody{
+				Message: "Invalid status parameter",
+				Details: map[string]interface{}{"allowed": []storage.TaskStatus{
+					storage.TaskOpen, storage.TaskInProgress, storage.TaskDone, storage.TaskCancelled,
+				}},
+			})
+			return
+		}
+	}
+	filter.Tag = r.URL.Query().Get("tag")
+
+	tasks, err := api.taskStore.ListTasks(filter, func(note storage.Note) bool { return api.listed(r, note) })
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to list tasks")
+		return
+	}
+
+	response := make([]taskResponse, 0, len(tasks))
+	for _, task := range tasks {
+		link := url.URL{Path: "/note/" + canonicalPath(task.Note)}
+		if task.Task.Heading != nil {
+			link.Fragment = task.Task.Heading.Anchor
+		}
+		response = append(response, taskResponse{
+			Task: task.Task,
+			Note: taskNote{ID: task.Note.ID, Slug: task.Note.Slug, Title: noteTitle(task.Note)},
+			Link: link.String(),
+		})
+	}
+
+	render.JSON(w, r, response)
+}
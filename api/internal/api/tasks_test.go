@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func TestListTasks(t *testing.T) {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore, WithTaskStore(noteStore))
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "Plans/Launch.md", Content: "---\ntitle: Launch Plan\ntags: [team]\n---\n## Before Launch\n- [ ] Book the venue 📅 2024-05-01\n- [x] Pick a date\n"})
+	noteStore.SaveNote(storage.Note{ID: "inbox", Content: "- [ ] Reply to mail #team"})
+	noteStore.SaveNote(storage.Note{ID: "secret", Content: "---\nvisibility: unlisted\n---\n- [ ] Hidden #team"})
+
+	list := func(query string) []taskResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/tasks"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status OK for %q, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var tasks []taskResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &tasks); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return tasks
+	}
+
+	tasks := list("?status=open&tag=team")
+	if len(tasks) != 2 {
+		t.Fatalf("Expected the two listed open tasks, got %+v", tasks)
+	}
+	task := tasks[0]
+	if task.Text != "Book the venue" || task.Due != "2024-05-01" || task.Line != 2 || task.Status != storage.TaskOpen {
+		t.Errorf("Unexpected task %+v", task.Task)
+	}
+	if task.Note != (taskNote{ID: "Plans/Launch.md", Slug: "plans/launch-plan", Title: "Launch Plan"}) {
+		t.Errorf("Unexpected note %+v", task.Note)
+	}
+	if task.Link != "/note/plans/launch-plan#before-launch" {
+		t.Errorf("Expected a link to the heading, got %q", task.Link)
+	}
+	if tasks[1].Text != "Reply to mail #team" || tasks[1].Link != "/note/inbox" || tasks[1].Heading != nil {
+		t.Errorf("Unexpected task %+v", tasks[1])
+	}
+
+	if tasks := list("?status=done"); len(tasks) != 1 || tasks[0].Text != "Pick a date" {
+		t.Errorf("Expected the done task, got %+v", tasks)
+	}
+	if tasks := list(""); len(tasks) != 3 {
+		t.Errorf("Expected every listed task, got %+v", tasks)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/tasks?status=later", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid status to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+type TrashStorer interface {
+	TrashNote(id, actor string) error
+	ListTrash() ([]storage.TrashedNote, error)
+	RestoreNote(id string) error
+	PurgeNote(id string) error
+}
+
+// trashNote moves the note to the trash in the name of the key that
+// authenticated r.
+func (api *API) trashNote(w http.ResponseWriter, r *http.Request, id string) {
+	var actor string
+	if key, ok := APIKeyFromContext(r.Context()); ok {
+		actor = key.Name
+	}
+
+	if err := api.trashStore.TrashNote(id, actor); err != nil {
+		writeStoreError(w, r, err, "Failed to delete note")
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"status": "Note moved to trash"})
+}
+
+// ListTrash returns the trashed notes the key may delete.
+func (api *API) ListTrash(w http.ResponseWriter, r *http.Request) {
+	trash, err := api.trashStore.ListTrash()
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to retrieve trash")
+		return
+	}
+
+	key, hasKey := APIKeyFromContext(r.Context())
+	response := make([]storage.TrashedNote, 0, len(trash))
+	for _, trashed := range trash {
+		if hasKey && !key.AllowsID(trashed.Note.ID) {
+			continue
+		}
+		response = append(response, trashed)
+	}
+
+	render.JSON(w, r, response)
+}
+
+func (api *API) RestoreNote(w http.ResponseWriter, r *http.Request) {
+	id := noteIDParam(r)
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "Note ID is required")
+		return
+	}
+
+	if !checkIDPermission(w, r, "restore", id) {
+		return
+	}
+
+	if err := api.trashStore.RestoreNote(id); err != nil {
+		writeStoreError(w, r, err, "Failed to restore note")
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"status": "Note restored successfully"})
+}
+
+func (api *API) PurgeNote(w http.ResponseWriter, r *http.Request) {
+	id := noteIDParam(r)
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "Note ID is required")
+		return
+	}
+
+	if !checkIDPermission(w, r, "purge", id) {
+		return
+	}
+
+	if err := api.trashStore.PurgeNote(id); err != nil {
+		writeStoreError(w, r, err, "Failed to purge note")
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"status": "Note purged successfully"})
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+func rootKeyRequest(r chi.Router, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-API-Key", "root-key")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestTrash(t *testing.T) {
+	noteStore := newNoteStore()
+	api := NewAPI(noteStore,
+		WithAuthenticator(NewAuthenticator(nil, "root-key", false)),
+		WithTrashStore(noteStore),
+	)
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "docs/guide", Content: "guide"})
+
+	if w := rootKeyRequest(r, "DELETE", "/note/docs%2Fguide"); w.Code != http.StatusOK {
+		t.Fatalf("Expected unpublish to succeed, got %d", w.Code)
+	}
+	if w := rootKeyRequest(r, "DELETE", "/note/docs%2Fguide"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected unpublishing a missing note to answer 404, got %d", w.Code)
+	}
+	if code := getWithCookie(r, "/note/docs%2Fguide", nil); code != http.StatusNotFound {
+		t.Errorf("Expected trashed note to be hidden, got %d", code)
+	}
+
+	w := rootKeyRequest(r, "GET", "/trash")
+	var trash []storage.TrashedNote
+	if err := json.Unmarshal(w.Body.Bytes(), &trash); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(trash) != 1 || trash[0].Note.ID != "docs/guide" || trash[0].DeletedBy != "API_KEY" {
+		t.Fatalf("Unexpected trash %+v", trash)
+	}
+
+	if w := rootKeyRequest(r, "POST", "/trash/docs%2Fguide/restore"); w.Code != http.StatusOK {
+		t.Fatalf("Expected restore to succeed, got %d", w.Code)
+	}
+	if code := getWithCookie(r, "/note/docs%2Fguide", nil); code != http.StatusOK {
+		t.Errorf("Expected restored note to be readable, got %d", code)
+	}
+	if w := rootKeyRequest(r, "POST", "/trash/docs%2Fguide/restore"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected restoring twice to answer 404, got %d", w.Code)
+	}
+
+	rootKeyRequest(r, "DELETE", "/note/docs%2Fguide")
+	noteStore.SaveNote(storage.Note{ID: "docs/guide", Content: "rewritten"})
+	if w := rootKeyRequest(r, "POST", "/trash/docs%2Fguide/restore"); w.Code != http.StatusConflict {
+		t.Errorf("Expected restore over a newer note to answer 409, got %d", w.Code)
+	}
+
+	if w := rootKeyRequest(r, "DELETE", "/trash/docs%2Fguide"); w.Code != http.StatusOK {
+		t.Errorf("Expected purge to succeed, got %d", w.Code)
+	}
+	if w := rootKeyRequest(r, "DELETE", "/trash/docs%2Fguide"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected purging twice to answer 404, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/trash", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected trash to require a key, got %d", w.Code)
+	}
+}
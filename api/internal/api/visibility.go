@@ -0,0 +1,208 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+const (
+	accessTokenTTL     = 24 * time.Hour
+	accessCookiePrefix = "mdp_access_"
+	accessTokenHeader  = "X-Access-Token"
+)
+
+var errNoteLocked = errors.New("note is password protected")
+
+type AccessStorer interface {
+	PasswordRealm(id string) (string, error)
+	CheckPassword(id, password string) (string, error)
+	SetFolderPassword(folder, password string) error
+	DeleteFolderPassword(folder string) error
+}
+
+// checkRead decides whether the request may read the note. Expired notes
+// are hidden, and so are drafts and notes scheduled for later unless a
+// preview link is used. Reader rules are applied next, and protected
+// notes then need an access token for the realm guarding them.
+func (api *API) checkRead(r *http.Request, note storage.Note) error {
+	now := time.Now()
+	if note.Expired(now) {
+		return errNoteHidden
+	}
+	if note.IsDraft() || note.Pending(now) {
+		if err := api.checkPreview(r, note); err != nil {
+			return err
+		}
+	}
+
+	if err := api.checkReader(r, note); err != nil {
+		return err
+	}
+
+	if note.Visibility() != storage.VisibilityProtected {
+		return nil
+	}
+
+	if api.accessStore == nil {
+		return errNoteLocked
+	}
+	realm, err := api.accessStore.PasswordRealm(note.ID)
+	if err != nil {
+		return errNoteLocked
+	}
+
+	for _, token := range accessTokens(r) {
+		claims, err := api.signer.verify(token, purposeAccess, time.Now())
+		if err == nil && claims.Subject == realm {
+			return nil
+		}
+	}
+	return errNoteLocked
+}
+
+// listed reports whether the note shows up in listings for the request.
+// Unlisted notes, drafts and notes that are not live never do; protected
+// notes only once unlocked.
+func (api *API) listed(r *http.Request, note storage.Note) bool {
+	now := time.Now()
+	if note.Visibility() == storage.VisibilityUnlisted || note.IsDraft() || note.Pending(now) || note.Expired(now) {
+		return false
+	}
+	return api.checkRead(r, note) == nil
+}
+
+func accessTokens(r *http.Request) []string {
+	tokens := r.Header.Values(accessTokenHeader)
+	for _, cookie := range r.Cookies() {
+		if strings.HasPrefix(cookie.Name, accessCookiePrefix) {
+			tokens = append(tokens, cookie.Value)
+		}
+	}
+	return tokens
+}
+
+func accessCookieName(realm string) string {
+	sum := sha256.Sum256([]byte(realm))
+	return accessCookiePrefix + hex.EncodeToString(sum[:8])
+}
+
+// writeReadError reports an error returned by checkRead.
+func writeReadError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errNoteLocked):
+		writeLocked(w, r)
+	case errors.Is(err, errNoteHidden):
+		writeError(w, r, http.StatusNotFound, "Note not found")
+	case errors.Is(err, errPreviewStale):
+		writeError(w, r, http.StatusGone, "Preview link is for an older revision of this note")
+	default:
+		writeReaderError(w, r, err)
+	}
+}
+
+func writeLocked(w http.ResponseWriter, r *http.Request) {
+	writeErrorBody(w, r, http.StatusUnauthorized, errorBody{
+		Code:    "note_locked",
+		Message: "Note is password protected",
+		Details: map[string]interface{}{"protected": true},
+	})
+}
+
+type unlockRequest struct {
+	Password string `json:"password"`
+}
+
+// UnlockNote exchanges the password of a protected note for an access
+// token, returned in the body and set as a cookie.
+func (api *API) UnlockNote(w http.ResponseWriter, r *http.Request) {
+	id := noteIDParam(r)
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "Note ID is required")
+		return
+	}
+
+	var req unlockRequest
+	if err := api.decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	note, err := api.noteStore.GetNote(id)
+	if err != nil {
+		writeStoreError(w, r, err, "Failed to retrieve note")
+		return
+	}
+	if note.Visibility() != storage.VisibilityProtected {
+		writeError(w, r, http.StatusBadRequest, "Note is not password protected")
+		return
+	}
+
+	realm, err := api.accessStore.CheckPassword(id, req.Password)
+	if errors.Is(err, storage.ErrWrongPassword) || errors.Is(err, storage.ErrNoPassword) {
+		writeLocked(w, r)
+		return
+	} else if err != nil {
+		writeStoreError(w, r, err, "Failed to check password")
+		return
+	}
+
+	expiresAt := time.Now().Add(accessTokenTTL)
+	token, err := api.signer.sign(tokenClaims{Purpose: purposeAccess, Subject: realm, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to issue access token")
+		return
+	}
+
+	setCookie(w, r, accessCookieName(realm), token, expiresAt)
+	render.JSON(w, r, map[string]interface{}{
+		"token":      token,
+		"expires_at": expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+type folderPasswordRequest struct {
+	Folder   string `json:"folder"`
+	Password string `json:"password"`
+}
+
+func (api *API) SetFolderPassword(w http.ResponseWriter, r *http.Request) {
+	var req folderPasswordRequest
+	if err := api.decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if req.Folder == "" || req.Password == "" {
+		writeError(w, r, http.StatusBadRequest, "Folder and password are required")
+		return
+	}
+
+	if err := api.accessStore.SetFolderPassword(req.Folder, req.Password); err != nil {
+		writeStoreError(w, r, err, "Failed to set folder password")
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"status": "Folder password set successfully"})
+}
+
+func (api *API) DeleteFolderPassword(w http.ResponseWriter, r *http.Request) {
+	folder := r.URL.Query().Get("folder")
+	if folder == "" {
+		writeError(w, r, http.StatusBadRequest, "Folder is required")
+		return
+	}
+
+	if err := api.accessStore.DeleteFolderPassword(folder); err != nil {
+		writeStoreError(w, r, err, "Failed to delete folder password")
+		return
+	}
+
+	render.JSON(w, r, map[string]string{"status": "Folder password deleted successfully"})
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+type MockAccessStore struct {
+	notes   map[string]string
+	folders map[string]string
+}
+
+func NewMockAccessStore() *MockAccessStore {
+	return &MockAccessStore{
+		notes:   make(map[string]string),
+		folders: make(map[string]string),
+	}
+}
+
+func (m *MockAccessStore) lookup(id string) (string, string, error) {
+	if password, exists := m.notes[id]; exists {
+		return "note:" + id, password, nil
+	}
+	for folder := id; strings.Contains(folder, "/"); {
+		folder = folder[:strings.LastIndex(folder, "/")]
+		if password, exists := m.folders[folder]; exists {
+			return "folder:" + folder, password, nil
+		}
+	}
+	return "", "", storage.ErrNoPassword
+}
+
+func (m *MockAccessStore) PasswordRealm(id string) (string, error) {
+	realm, _, err := m.lookup(id)
+	return realm, err
+}
+
+func (m *MockAccessStore) CheckPassword(id, password string) (string, error) {
+	realm, want, err := m.lookup(id)
+	if err != nil {
+		return "", err
+	}
+	if password != want {
+		return "", storage.ErrWrongPassword
+	}
+	return realm, nil
+}
+
+func (m *MockAccessStore) SetFolderPassword(folder, password string) error {
+	m.folders[folder] = password
+	return nil
+}
+
+func (m *MockAccessStore) DeleteFolderPassword(folder string) error {
+	delete(m.folders, folder)
+	return nil
+}
+
+func newVisibilityRouter() (chi.Router, *storage.NoteStore, *MockAccessStore) {
+	noteStore := newNoteStore()
+	accessStore := NewMockAccessStore()
+	api := NewAPI(noteStore, WithAccessStore(accessStore))
+
+	r := chi.NewRouter()
+	api.RegisterRoutes(r)
+
+	noteStore.SaveNote(storage.Note{ID: "public", Content: "public"})
+	noteStore.SaveNote(storage.Note{ID: "unlisted", Content: "---\nvisibility: unlisted\n---\nunlisted"})
+	noteStore.SaveNote(storage.Note{ID: "team/protected", Content: "---\nvisibility: protected\n---\nprotected"})
+	accessStore.SetFolderPassword("team", "secret")
+
+	return r, noteStore, accessStore
+}
+
+func listedIDs(t *testing.T, r chi.Router, cookies []*http.Cookie) []string {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/notes", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var notes []storage.Note
+	if err := json.Unmarshal(w.Body.Bytes(), &notes); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	ids := make([]string, 0, len(notes))
+	for _, note := range notes {
+		ids = append(ids, note.ID)
+	}
+	return ids
+}
+
+func TestVisibility(t *testing.T) {
+	r, _, _ := newVisibilityRouter()
+
+	if ids := listedIDs(t, r, nil); len(ids) != 1 || ids[0] != "public" {
+		t.Errorf("Expected only the public note to be listed, got %v", ids)
+	}
+
+	testCases := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{"/note/public", http.StatusOK},
+		{"/note/unlisted", http.StatusOK},
+		{"/note/team%2Fprotected", http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tc.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestUnlockNote(t *testing.T) {
+	r, _, _ := newVisibilityRouter()
+
+	req := httptest.NewRequest("POST", "/note/team%2Fprotected/unlock", bytes.NewBufferString(`{"password":"wrong"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for a wrong password, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/note/team%2Fprotected/unlock", bytes.NewBufferString(`{"password":"secret"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("Expected one HttpOnly access cookie, got %v", cookies)
+	}
+
+	var unlocked struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &unlocked); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	req = httptest.NewRequest("GET", "/note/team%2Fprotected", nil)
+	req.Header.Set(accessTokenHeader, unlocked.Token)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected token to unlock the note, got %d", w.Code)
+	}
+
+	if ids := listedIDs(t, r, cookies); len(ids) != 2 {
+		t.Errorf("Expected the unlocked note to be listed, got %v", ids)
+	}
+}
+
+func TestTokenSigner(t *testing.T) {
+	signer := tokenSigner{secret: []byte("secret")}
+	token, err := signer.sign(tokenClaims{Purpose: purposeAccess, Subject: "note:a", ExpiresAt: 2000000000})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	if _, err := (tokenSigner{secret: []byte("other")}).verify(token, purposeAccess, time.Unix(1000000000, 0)); err == nil {
+		t.Errorf("Expected token signed with another secret to be rejected")
+	}
+	if _, err := signer.verify(token, purposeAccess, time.Unix(2000000000, 0)); err == nil {
+		t.Errorf("Expected expired token to be rejected")
+	}
+	claims, err := signer.verify(token, purposeAccess, time.Unix(1000000000, 0))
+	if err != nil || claims.Subject != "note:a" {
+		t.Errorf("Expected valid token, got %+v, %v", claims, err)
+	}
+}
@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type ExpiredNoteDeleter interface {
+	DeleteExpiredNotes() (int, error)
+}
+
+// DeleteExpiredNotes deletes notes whose expire_at has passed, checking
+// every interval until ctx is cancelled.
+func DeleteExpiredNotes(ctx context.Context, notes ExpiredNoteDeleter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := notes.DeleteExpiredNotes()
+		if err != nil {
+			log.Println("Failed to delete expired notes:", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d expired notes", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+const (
+	EventPublished = "published"
+	EventExpired   = "expired"
+)
+
+// Event is fired when a scheduled note goes live or expires.
+type Event struct {
+	Type   string    `json:"type"`
+	NoteID string    `json:"note_id"`
+	At     time.Time `json:"at"`
+}
+
+type ScheduleStorer interface {
+	ListEntries() ([]storage.ScheduleEntry, error)
+	SaveEntry(entry storage.ScheduleEntry) error
+}
+
+// Scheduler polls the persisted schedule and fires events for entries
+// that came due, including ones missed while the server was down.
+// Visibility itself is derived from publish_at and expire_at when notes
+// are read, so a late tick never exposes a note early.
+type Scheduler struct {
+	schedule ScheduleStorer
+	interval time.Duration
+	notify   func(Event)
+	now      func() time.Time
+}
+
+func New(schedule ScheduleStorer, interval time.Duration, notify func(Event)) *Scheduler {
+	return &Scheduler{
+		schedule: schedule,
+		interval: interval,
+		notify:   notify,
+		now:      time.Now,
+	}
+}
+
+// Run fires due events every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Tick(); err != nil {
+			log.Println("Scheduler tick failed:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick fires the events that are due now and records them as handled.
+func (s *Scheduler) Tick() error {
+	entries, err := s.schedule.ListEntries()
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	for _, entry := range entries {
+		changed := false
+
+		if entry.PublishAt != nil && !now.Before(*entry.PublishAt) {
+			s.notify(Event{Type: EventPublished, NoteID: entry.NoteID, At: *entry.PublishAt})
+			entry.PublishAt = nil
+			changed = true
+		}
+		if entry.ExpireAt != nil && !now.Before(*entry.ExpireAt) {
+			s.notify(Event{Type: EventExpired, NoteID: entry.NoteID, At: *entry.ExpireAt})
+			entry.ExpireAt = nil
+			changed = true
+		}
+
+		if changed {
+			if err := s.schedule.SaveEntry(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+type mockScheduleStore struct {
+	entries map[string]storage.ScheduleEntry
+}
+
+func (m *mockScheduleStore) ListEntries() ([]storage.ScheduleEntry, error) {
+	entries := []storage.ScheduleEntry{}
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (m *mockScheduleStore) SaveEntry(entry storage.ScheduleEntry) error {
+	if entry.PublishAt == nil && entry.ExpireAt == nil {
+		delete(m.entries, entry.NoteID)
+		return nil
+	}
+	m.entries[entry.NoteID] = entry
+	return nil
+}
+
+func TestTick(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	missed := now.Add(-6 * time.Hour)
+	later := now.Add(time.Hour)
+
+	store := &mockScheduleStore{entries: map[string]storage.ScheduleEntry{
+		"missed": {NoteID: "missed", PublishAt: &missed},
+		"later":  {NoteID: "later", PublishAt: &later},
+		"window": {NoteID: "window", PublishAt: &missed, ExpireAt: &later},
+	}}
+
+	var events []Event
+	s := New(store, time.Minute, func(event Event) { events = append(events, event) })
+	s.now = func() time.Time { return now }
+
+	if err := s.Tick(); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected the two missed publishes to fire, got %+v", events)
+	}
+	for _, event := range events {
+		if event.Type != EventPublished || !event.At.Equal(missed) {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	}
+	if _, exists := store.entries["missed"]; exists {
+		t.Errorf("Expected handled entry to be removed")
+	}
+	if entry := store.entries["window"]; entry.PublishAt != nil || entry.ExpireAt == nil {
+		t.Errorf("Expected only the expiry to stay pending, got %+v", entry)
+	}
+
+	events = nil
+	if err := s.Tick(); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected events to fire once, got %+v", events)
+	}
+
+	s.now = func() time.Time { return later }
+	if err := s.Tick(); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	if len(events) != 2 || len(store.entries) != 0 {
+		t.Errorf("Expected the late publish and expiry to fire, got %+v", events)
+	}
+}
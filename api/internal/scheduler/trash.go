@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type TrashPurger interface {
+	PurgeTrash(cutoff time.Time) (int, error)
+}
+
+// PurgeTrash permanently deletes notes that have been in the trash for
+// longer than retention, checking every interval until ctx is cancelled.
+func PurgeTrash(ctx context.Context, trash TrashPurger, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := trash.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			log.Println("Failed to purge trash:", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d notes from the trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Webhook returns a notifier posting each event as JSON to url, for
+// example to trigger a rebuild of the site. Failures are logged.
+func Webhook(url string) func(Event) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return func(event Event) {
+		body, err := json.Marshal(event)
+		if err != nil {
+			log.Println("Failed to encode schedule event:", err)
+			return
+		}
+
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Println("Failed to deliver schedule event:", err)
+			return
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			log.Printf("Schedule webhook answered %s for %s of %q", resp.Status, event.Type, event.NoteID)
+		}
+	}
+}
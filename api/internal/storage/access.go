@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	VisibilityPublic    = "public"
+	VisibilityUnlisted  = "unlisted"
+	VisibilityProtected = "protected"
+)
+
+const (
+	notePasswordPrefix   = systemKeyPrefix + "password/note/"
+	folderPasswordPrefix = systemKeyPrefix + "password/folder/"
+	secretPrefix         = systemKeyPrefix + "secret/"
+
+	passwordIterations = 100000
+)
+
+var (
+	ErrInvalidVisibility = errors.New("invalid visibility")
+	ErrNoPassword        = errors.New("no password set")
+	ErrWrongPassword     = errors.New("wrong password")
+)
+
+// Visibility returns the note's visibility from its metadata, defaulting
+// to public.
+func (n Note) Visibility() string {
+	if v, ok := n.Metadata["visibility"].(string); ok && v != "" {
+		return v
+	}
+	return VisibilityPublic
+}
+
+// ValidateVisibility checks that the note's visibility is a known value.
+func ValidateVisibility(note Note) error {
+	switch v := note.Visibility(); v {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityProtected:
+		return nil
+	default:
+		return fmt.Errorf("%w %q: must be public, unlisted or protected", ErrInvalidVisibility, v)
+	}
+}
+
+// AccessStore keeps the passwords of protected notes. A note either has
+// its own password, set from the "password" frontmatter field on publish,
+// or inherits the password of its closest folder.
+type AccessStore struct {
+	store Store
+}
+
+func NewAccessStore(store Store) *AccessStore {
+	return &AccessStore{store: store}
+}
+
+func (as *AccessStore) SetNotePassword(id, password string) error {
+	return as.setPassword(notePasswordPrefix+id, password)
+}
+
+func (as *AccessStore) DeleteNotePassword(id string) error {
+	return as.store.Delete(notePasswordPrefix + id)
+}
+
+// moveNotePassword moves the note's own password, if any, to the ID to.
+func (as *AccessStore) moveNotePassword(from, to string) error {
+	hash, err := as.store.Get(notePasswordPrefix + from)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := as.store.Set(notePasswordPrefix+to, hash); err != nil {
+		return err
+	}
+	return as.DeleteNotePassword(from)
+}
+
+func (as *AccessStore) SetFolderPassword(folder, password string) error {
+	return as.setPassword(folderPasswordPrefix+strings.Trim(folder, "/"), password)
+}
+
+func (as *AccessStore) DeleteFolderPassword(folder string) error {
+	return as.store.Delete(folderPasswordPrefix + strings.Trim(folder, "/"))
+}
+
+// PasswordRealm returns the realm whose password guards the note: either
+// "note:<id>" or "folder:<path>" for the closest folder with a password.
+func (as *AccessStore) PasswordRealm(id string) (string, error) {
+	realm, _, err := as.lookup(id)
+	return realm, err
+}
+
+// CheckPassword verifies password against the note's realm and returns
+// the realm on success.
+func (as *AccessStore) CheckPassword(id, password string) (string, error) {
+	realm, hash, err := as.lookup(id)
+	if err != nil {
+		return "", err
+	}
+	if !verifyPassword(password, hash) {
+		return "", ErrWrongPassword
+	}
+	return realm, nil
+}
+
+func (as *AccessStore) lookup(id string) (string, string, error) {
+	if hash, err := as.store.Get(notePasswordPrefix + id); err == nil {
+		return "note:" + id, string(hash), nil
+	}
+
+	folder := id
+	for {
+		i := strings.LastIndex(folder, "/")
+		if i < 0 {
+			return "", "", ErrNoPassword
+		}
+		folder = folder[:i]
+		if hash, err := as.store.Get(folderPasswordPrefix + folder); err == nil {
+			return "folder:" + folder, string(hash), nil
+		}
+	}
+}
+
+func (as *AccessStore) setPassword(key, password string) error {
+	if password == "" {
+		return ErrNoPassword
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return as.store.Set(key, []byte(hash))
+}
+
+// LoadOrCreateSecret returns the named secret, generating and storing a
+// random one on first use so signed tokens survive restarts.
+func LoadOrCreateSecret(store Store, name string) ([]byte, error) {
+	if secret, err := store.Get(secretPrefix + name); err == nil {
+		return secret, nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := store.Set(secretPrefix+name, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, sha256.Size)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func verifyPassword(password, encoded string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
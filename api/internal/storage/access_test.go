@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestAccessStore(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "access-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	accessStore := NewAccessStore(store)
+	noteStore := NewNoteStore(store)
+
+	if _, err := accessStore.PasswordRealm("internal/handbook"); !errors.Is(err, ErrNoPassword) {
+		t.Errorf("Expected ErrNoPassword, got %v", err)
+	}
+
+	if err := accessStore.SetFolderPassword("internal", "folder-secret"); err != nil {
+		t.Fatalf("Failed to set folder password: %v", err)
+	}
+
+	realm, err := accessStore.CheckPassword("internal/team/handbook", "folder-secret")
+	if err != nil {
+		t.Fatalf("Failed to check folder password: %v", err)
+	}
+	if realm != "folder:internal" {
+		t.Errorf("Expected realm folder:internal, got %q", realm)
+	}
+
+	note := Note{
+		ID:      "internal/salaries",
+		Content: "---\nvisibility: protected\npassword: note-secret\n---\n# Salaries",
+	}
+	if err := noteStore.SaveNote(note); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+
+	saved, err := noteStore.GetNote(note.ID)
+	if err != nil {
+		t.Fatalf("Failed to get note: %v", err)
+	}
+	if _, exists := saved.Metadata["password"]; exists {
+		t.Errorf("Expected password to be stripped from metadata")
+	}
+	if saved.Visibility() != VisibilityProtected {
+		t.Errorf("Expected protected visibility, got %q", saved.Visibility())
+	}
+
+	realm, err = accessStore.CheckPassword(note.ID, "note-secret")
+	if err != nil {
+		t.Fatalf("Failed to check note password: %v", err)
+	}
+	if realm != "note:internal/salaries" {
+		t.Errorf("Expected note realm, got %q", realm)
+	}
+	if _, err := accessStore.CheckPassword(note.ID, "folder-secret"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected note password to take precedence, got %v", err)
+	}
+
+	if err := noteStore.DeleteNote(note.ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	if realm, _ := accessStore.PasswordRealm(note.ID); realm != "folder:internal" {
+		t.Errorf("Expected note password to be removed, got realm %q", realm)
+	}
+
+	if err := noteStore.SaveNote(Note{ID: "bad", Content: "---\nvisibility: secret\n---\nx"}); !errors.Is(err, ErrInvalidVisibility) {
+		t.Errorf("Expected ErrInvalidVisibility, got %v", err)
+	}
+
+	notes, err := noteStore.ListNotes()
+	if err != nil {
+		t.Fatalf("Failed to list notes: %v", err)
+	}
+	if len(notes) != 0 {
+		t.Errorf("Expected passwords to be excluded from notes, got %v", notes)
+	}
+}
+
+func TestSaveNoteStoresPasswordLast(t *testing.T) {
+	store := NewMemoryStore()
+	accessStore := NewAccessStore(store)
+	noteStore := NewNoteStore(store)
+
+	for _, content := range []string{
+		"---\nvisibility: secret\npassword: hunter2\n---\nx",
+		"---\ntitle: [a, b]\npassword: hunter2\n---\nx",
+	} {
+		if err := noteStore.SaveNote(Note{ID: "rejected", Content: content}); err == nil {
+			t.Fatalf("Expected %q to be rejected", content)
+		}
+		if _, err := accessStore.PasswordRealm("rejected"); !errors.Is(err, ErrNoPassword) {
+			t.Errorf("Expected a rejected publish to leave no password, got %v", err)
+		}
+	}
+
+	err := noteStore.SaveNote(Note{ID: "empty", Content: "---\nvisibility: protected\npassword: \"\"\n---\nx"})
+	var validation *ValidationError
+	if !errors.As(err, &validation) || len(validation.Violations) != 1 || validation.Violations[0].Field != "metadata.password" {
+		t.Errorf("Expected an empty password to be a metadata.password violation, got %v", err)
+	}
+}
+
+func TestLoadOrCreateSecret(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "secret-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	first, err := LoadOrCreateSecret(store, "access")
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	second, err := LoadOrCreateSecret(store, "access")
+	if err != nil {
+		t.Fatalf("Failed to load secret: %v", err)
+	}
+	if len(first) != 32 || !bytes.Equal(first, second) {
+		t.Errorf("Expected a stable 32 byte secret, got %x and %x", first, second)
+	}
+}
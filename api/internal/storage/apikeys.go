@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ScopePublish = "publish"
+	ScopeDelete  = "delete"
+	ScopeAdmin   = "admin"
+)
+
+const (
+	apiKeyPrefix      = systemKeyPrefix + "apikey/"
+	apiKeyTokenPrefix = "mdp_"
+
+	// lastUsedResolution limits how often verification writes back the
+	// last-used timestamp of a key.
+	lastUsedResolution = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrUnknownScope   = errors.New("unknown scope")
+	ErrAPIKeyNotFound = fmt.Errorf("API key %w", ErrNotFound)
+)
+
+var knownScopes = map[string]bool{
+	ScopePublish: true,
+	ScopeDelete:  true,
+	ScopeAdmin:   true,
+}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Hash       string     `json:"hash,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	Prefixes   []string   `json:"prefixes,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether the key grants scope. The admin scope grants
+// every other scope.
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsID reports whether the key may modify the note with the given ID.
+// Prefixes are ID patterns as understood by MatchIDPattern. A key without
+// prefixes is unrestricted.
+func (k APIKey) AllowsID(id string) bool {
+	if len(k.Prefixes) == 0 {
+		return true
+	}
+	for _, pattern := range k.Prefixes {
+		if MatchIDPattern(pattern, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the key is neither revoked nor expired at now.
+func (k APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+type KeyStore struct {
+	store Store
+	now   func() time.Time
+
+	// mu serialises updates of stored keys, so that recording when a key
+	// was last used never writes back a key revoked in the meantime.
+	mu sync.Mutex
+}
+
+func NewKeyStore(store Store) *KeyStore {
+	return &KeyStore{store: store, now: time.Now}
+}
+
+// CreateAPIKey stores a new key and returns it together with the plain
+// token. The token is not stored and cannot be recovered later.
+func (ks *KeyStore) CreateAPIKey(name string, scopes, prefixes []string, expiresAt *time.Time) (APIKey, string, error) {
+	if len(scopes) == 0 {
+		return APIKey{}, "", fmt.Errorf("%w: at least one scope is required", ErrUnknownScope)
+	}
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return APIKey{}, "", fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+	}
+	for _, pattern := range prefixes {
+		if err := ValidateIDPattern(pattern); err != nil {
+			return APIKey{}, "", err
+		}
+	}
+
+	id, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	key := APIKey{
+		ID:        id,
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Scopes:    scopes,
+		Prefixes:  prefixes,
+		CreatedAt: ks.now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	if err := ks.save(key); err != nil {
+		return APIKey{}, "", err
+	}
+
+	return key, apiKeyTokenPrefix + id + "." + secret, nil
+}
+
+func (ks *KeyStore) GetAPIKey(id string) (APIKey, error) {
+	var key APIKey
+
+	data, err := ks.store.Get(apiKeyPrefix + id)
+	if errors.Is(err, ErrNotFound) {
+		return key, ErrAPIKeyNotFound
+	} else if err != nil {
+		return key, err
+	}
+
+	err = json.Unmarshal(data, &key)
+	return key, err
+}
+
+func (ks *KeyStore) ListAPIKeys() ([]APIKey, error) {
+	ids, err := listIDs(ks.store, apiKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys := []APIKey{}
+	for _, id := range ids {
+		key, err := ks.GetAPIKey(id)
+		if err != nil {
+			continue
+		}
+		apiKeys = append(apiKeys, key)
+	}
+
+	return apiKeys, nil
+}
+
+// RevokeAPIKey marks a key as revoked. Revoked keys are kept so they still
+// show up when listing keys.
+func (ks *KeyStore) RevokeAPIKey(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, err := ks.GetAPIKey(id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	now := ks.now().UTC()
+	key.RevokedAt = &now
+	return ks.save(key)
+}
+
+// VerifyAPIKey resolves a token to its key, comparing the secret in
+// constant time. Any failure is reported as ErrInvalidAPIKey.
+func (ks *KeyStore) VerifyAPIKey(token string) (APIKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, apiKeyTokenPrefix), ".")
+	if !ok || id == "" || secret == "" {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	key, err := ks.GetAPIKey(id)
+	if err != nil {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) != 1 {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	now := ks.now().UTC()
+	if !key.Active(now) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		return ks.touch(id, now)
+	}
+
+	return key, nil
+}
+
+// touch records that the key was used at now. The key is read again
+// under the lock, so a revocation since it was verified is kept and
+// fails the verification.
+func (ks *KeyStore) touch(id string, now time.Time) (APIKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, err := ks.GetAPIKey(id)
+	if err != nil || !key.Active(now) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	key.LastUsedAt = &now
+	if err := ks.save(key); err != nil {
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+// HasActiveAPIKeys reports whether at least one key can still be used.
+func (ks *KeyStore) HasActiveAPIKeys() (bool, error) {
+	keys, err := ks.ListAPIKeys()
+	if err != nil {
+		return false, err
+	}
+
+	now := ks.now()
+	for _, key := range keys {
+		if key.Active(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (ks *KeyStore) save(key APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	return ks.store.Set(apiKeyPrefix+key.ID, data)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestKeyStore(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "keystore-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	keyStore := NewKeyStore(store)
+
+	key, token, err := keyStore.CreateAPIKey("ci", []string{ScopePublish}, []string{"docs/"}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if key.Hash == "" || key.Hash == token {
+		t.Errorf("Expected a hashed secret, got %q", key.Hash)
+	}
+
+	verified, err := keyStore.VerifyAPIKey(token)
+	if err != nil {
+		t.Fatalf("Failed to verify key: %v", err)
+	}
+	if verified.ID != key.ID {
+		t.Errorf("Expected key ID %q, got %q", key.ID, verified.ID)
+	}
+	if verified.LastUsedAt == nil {
+		t.Errorf("Expected last used time to be recorded")
+	}
+	if !verified.HasScope(ScopePublish) || verified.HasScope(ScopeDelete) {
+		t.Errorf("Unexpected scopes %v", verified.Scopes)
+	}
+	if !verified.AllowsID("docs/intro") || verified.AllowsID("blog/intro") {
+		t.Errorf("Unexpected prefix restriction %v", verified.Prefixes)
+	}
+
+	if _, err := keyStore.VerifyAPIKey(token + "x"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for wrong secret, got %v", err)
+	}
+
+	noteStore := NewNoteStore(store)
+	notes, err := noteStore.ListNotes()
+	if err != nil {
+		t.Fatalf("Failed to list notes: %v", err)
+	}
+	if len(notes) != 0 {
+		t.Errorf("Expected API keys to be excluded from notes, got %v", notes)
+	}
+
+	if err := keyStore.RevokeAPIKey(key.ID); err != nil {
+		t.Fatalf("Failed to revoke key: %v", err)
+	}
+	if _, err := keyStore.VerifyAPIKey(token); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected revoked key to be rejected, got %v", err)
+	}
+
+	keys, err := keyStore.ListAPIKeys()
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("Expected one revoked key, got %v", keys)
+	}
+
+	hasKeys, err := keyStore.HasActiveAPIKeys()
+	if err != nil {
+		t.Fatalf("Failed to check active keys: %v", err)
+	}
+	if hasKeys {
+		t.Errorf("Expected no active keys after revocation")
+	}
+}
+
+func TestKeyStoreExpiry(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "keystore-expiry-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	keyStore := NewKeyStore(store)
+
+	expiresAt := time.Now().Add(time.Hour)
+	_, token, err := keyStore.CreateAPIKey("temp", []string{ScopeAdmin}, nil, &expiresAt)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	if _, err := keyStore.VerifyAPIKey(token); err != nil {
+		t.Fatalf("Expected key to be valid before expiry, got %v", err)
+	}
+
+	keyStore.now = func() time.Time { return expiresAt.Add(time.Second) }
+	if _, err := keyStore.VerifyAPIKey(token); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected expired key to be rejected, got %v", err)
+	}
+
+	if _, _, err := keyStore.CreateAPIKey("bad", []string{"write"}, nil, nil); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("Expected ErrUnknownScope, got %v", err)
+	}
+}
+
+// revokingStore revokes a key the first time it is read, as if a
+// revocation landed between verifying the key and recording its use.
+type revokingStore struct {
+	Store
+	keyStore *KeyStore
+	id       string
+	revoked  bool
+}
+
+func (s *revokingStore) Get(key string) ([]byte, error) {
+	data, err := s.Store.Get(key)
+	if key == apiKeyPrefix+s.id && !s.revoked {
+		s.revoked = true
+		if err := s.keyStore.RevokeAPIKey(s.id); err != nil {
+			return nil, err
+		}
+	}
+	return data, err
+}
+
+func TestVerifyAPIKeyKeepsRevocation(t *testing.T) {
+	store := &revokingStore{Store: NewMemoryStore()}
+	keyStore := NewKeyStore(store)
+	store.keyStore = keyStore
+
+	key, token, err := keyStore.CreateAPIKey("ci", []string{ScopePublish}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	store.id = key.ID
+
+	if _, err := keyStore.VerifyAPIKey(token); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected a key revoked during verification to be rejected, got %v", err)
+	}
+	stored, err := keyStore.GetAPIKey(key.ID)
+	if err != nil || stored.RevokedAt == nil {
+		t.Fatalf("Expected the revocation to be kept, got %+v, %v", stored, err)
+	}
+	if _, err := keyStore.VerifyAPIKey(token); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected the revoked key to stay rejected, got %v", err)
+	}
+}
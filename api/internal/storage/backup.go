@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// exportFormat identifies the portable export in its header line.
+const exportFormat = "md-publisher-export"
+
+var (
+	ErrInvalidExport = errors.New("invalid export")
+	ErrStoreNotEmpty = errors.New("store is not empty")
+)
+
+// Snapshotter is implemented by stores that can read every key from a
+// single consistent snapshot.
+type Snapshotter interface {
+	Snapshot(fn func(key string, value []byte) error) error
+}
+
+// Backup streams the entries written at or after version since in
+// Badger's backup format and returns the since of the next incremental
+// backup. Since 0 makes a full backup.
+func (s *BadgerStore) Backup(w io.Writer, since uint64) (uint64, error) {
+	last, err := s.db.Backup(w, since)
+	if err != nil {
+		return 0, err
+	}
+	return max(last+1, since), nil
+}
+
+// NextSince returns the since of a backup of the changes made after this
+// call.
+func (s *BadgerStore) NextSince() uint64 {
+	return s.db.MaxVersion() + 1
+}
+
+// Load applies a backup written by Backup. Incremental backups must be
+// loaded in the order they were taken, after the full backup they follow.
+func (s *BadgerStore) Load(r io.Reader) error {
+	return s.db.Load(r, 256)
+}
+
+func (s *BadgerStore) Snapshot(fn func(key string, value []byte) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := fn(string(item.Key()), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type exportHeader struct {
+	Format        string `json:"format"`
+	SchemaVersion int    `json:"schema_version"`
+}
+
+type exportEntry struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Export writes every key of the store as JSON lines: a header naming the
+// format and schema version, then one {"key", "value"} object per key with
+// the value base64 encoded. The export does not depend on the backend, so
+// it can be imported into any other. Stores implementing Snapshotter are
+// exported from a consistent snapshot.
+func Export(store Store, w io.Writer) error {
+	version, err := SchemaVersion(store)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(exportHeader{Format: exportFormat, SchemaVersion: version}); err != nil {
+		return err
+	}
+
+	write := func(key string, value []byte) error {
+		return enc.Encode(exportEntry{Key: key, Value: value})
+	}
+
+	if snapshotter, ok := store.(Snapshotter); ok {
+		err = snapshotter.Snapshot(write)
+	} else {
+		err = eachKey(store, write)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func eachKey(store Store, fn func(key string, value []byte) error) error {
+	keys, err := store.ListKeys("")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		value, err := store.Get(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import writes the keys of an export into an empty store and returns how
+// many it wrote. The store takes the export's schema version, and is
+// upgraded from there by the next Migrate.
+func Import(store Store, r io.Reader) (int, error) {
+	keys, err := store.ListKeys("")
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if key != schemaVersionKey {
+			return 0, ErrStoreNotEmpty
+		}
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	var header exportHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	if header.Format != exportFormat {
+		return 0, fmt.Errorf("%w: unknown format %q", ErrInvalidExport, header.Format)
+	}
+	if header.SchemaVersion > latestSchemaVersion() {
+		return 0, fmt.Errorf("%w: export is at version %d", ErrSchemaTooNew, header.SchemaVersion)
+	}
+
+	if err := store.Delete(schemaVersionKey); err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for {
+		var entry exportEntry
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+			return imported, nil
+		} else if err != nil {
+			return imported, fmt.Errorf("%w: entry %d: %v", ErrInvalidExport, imported+1, err)
+		}
+
+		if err := store.Set(entry.Key, entry.Value); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBadgerIncrementalBackup(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "backup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir + "/source")
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+	noteStore := NewNoteStore(store)
+
+	noteStore.SaveNote(Note{ID: "first", Content: "one"})
+
+	var full bytes.Buffer
+	since, err := store.Backup(&full, 0)
+	if err != nil {
+		t.Fatalf("Failed to back up: %v", err)
+	}
+
+	noteStore.SaveNote(Note{ID: "second", Content: "two"})
+	noteStore.DeleteNote("first")
+
+	var incremental bytes.Buffer
+	if _, err := store.Backup(&incremental, since); err != nil {
+		t.Fatalf("Failed to back up incrementally: %v", err)
+	}
+
+	restored, err := NewBadgerStore(tempDir + "/restored")
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer restored.Close()
+
+	if err := restored.Load(&full); err != nil {
+		t.Fatalf("Failed to load full backup: %v", err)
+	}
+	if _, err := NewNoteStore(restored).GetNote("first"); err != nil {
+		t.Errorf("Expected the full backup to hold the first note, got %v", err)
+	}
+
+	if err := restored.Load(&incremental); err != nil {
+		t.Fatalf("Failed to load incremental backup: %v", err)
+	}
+	if _, err := NewNoteStore(restored).GetNote("second"); err != nil {
+		t.Errorf("Expected the incremental backup to add the second note, got %v", err)
+	}
+	if _, err := NewNoteStore(restored).GetNote("first"); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected the incremental backup to delete the first note, got %v", err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "export-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	source, err := NewBadgerStore(tempDir + "/badger")
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer source.Close()
+	Migrate(source)
+
+	NewNoteStore(source).SaveNote(Note{ID: "docs/guide", Content: "---\ntitle: Guide\n---\nRead me."})
+	source.Set(secretPrefix+"binary", []byte{0, 1, 2, 255})
+
+	var export bytes.Buffer
+	if err := Export(source, &export); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if !strings.HasPrefix(export.String(), `{"format":"md-publisher-export","schema_version":7}`) {
+		t.Errorf("Unexpected export header in %q", export.String())
+	}
+
+	target, err := NewFileStore(tempDir + "/files")
+	if err != nil {
+		t.Fatalf("Failed to create FileStore: %v", err)
+	}
+	imported, err := Import(target, bytes.NewReader(export.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if imported != 4 {
+		t.Errorf("Expected 4 keys to be imported, got %d", imported)
+	}
+
+	note, err := NewNoteStore(target).GetNote("docs/guide")
+	if err != nil || note.Metadata["title"] != "Guide" || note.Content != "Read me." {
+		t.Errorf("Expected the note in the target store, got %+v, %v", note, err)
+	}
+	if secret, _ := target.Get(secretPrefix + "binary"); !bytes.Equal(secret, []byte{0, 1, 2, 255}) {
+		t.Errorf("Expected binary values to survive, got %v", secret)
+	}
+
+	if _, err := Import(target, bytes.NewReader(export.Bytes())); !errors.Is(err, ErrStoreNotEmpty) {
+		t.Errorf("Expected importing into a non-empty store to fail, got %v", err)
+	}
+	if _, err := Import(NewMemoryStore(), strings.NewReader(`{"format":"other"}`)); !errors.Is(err, ErrInvalidExport) {
+		t.Errorf("Expected an unknown format to be rejected, got %v", err)
+	}
+	if _, err := Import(NewMemoryStore(), strings.NewReader(`{"format":"md-publisher-export","schema_version":99}`)); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Expected a newer schema to be rejected, got %v", err)
+	}
+}
+
+func TestImportLegacyExport(t *testing.T) {
+	legacy := NewMemoryStore()
+	legacy.Set("welcome", []byte(`{"id":"welcome","content":"hello"}`))
+	legacy.Set("_sys/secret/access", []byte("secret"))
+
+	var export bytes.Buffer
+	if err := Export(legacy, &export); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	target := NewMemoryStore()
+	Migrate(target)
+	if _, err := Import(target, &export); err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if _, _, err := Migrate(target); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if note, err := NewNoteStore(target).GetNote("welcome"); err != nil || note.Content != "hello" {
+		t.Errorf("Expected the legacy note to be migrated, got %+v, %v", note, err)
+	}
+	if secret, err := target.Get(secretPrefix + "access"); err != nil || string(secret) != "secret" {
+		t.Errorf("Expected the legacy secret to be migrated, got %q, %v", secret, err)
+	}
+}
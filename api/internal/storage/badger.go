@@ -2,6 +2,10 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 )
@@ -11,23 +15,38 @@ type Store interface {
 	Set(key string, value []byte) error
 	Delete(key string) error
 	Close() error
-	ListKeys() ([]string, error)
+	// ListKeys returns the keys starting with prefix, in byte order.
+	ListKeys(prefix string) ([]string, error)
 }
 
+// ErrNotFound is returned by Store.Get for a missing key.
+var ErrNotFound = errors.New("not found")
+
 type BadgerStore struct {
-	db *badger.DB
+	db       *badger.DB
+	dir      string
+	valueDir string
+
+	mu          sync.Mutex
+	maintenance *maintenance
+	gc          GCStats
 }
 
-func NewBadgerStore(dataPath string) (*BadgerStore, error) {
+func NewBadgerStore(dataPath string, options ...BadgerOption) (*BadgerStore, error) {
 	opts := badger.DefaultOptions(dataPath)
 	opts.Logger = nil
+	for _, option := range options {
+		option(&opts)
+	}
 
 	db, err := badger.Open(opts)
-	if err != nil {
+	if errors.Is(err, badger.ErrEncryptionKeyMismatch) {
+		return nil, fmt.Errorf("%w in %s", ErrWrongEncryptionKey, dataPath)
+	} else if err != nil {
 		return nil, err
 	}
 
-	return &BadgerStore{db: db}, nil
+	return &BadgerStore{db: db, dir: opts.Dir, valueDir: opts.ValueDir}, nil
 }
 
 func (s *BadgerStore) Get(key string) ([]byte, error) {
@@ -41,6 +60,9 @@ func (s *BadgerStore) Get(key string) ([]byte, error) {
 		value, err = item.ValueCopy(nil)
 		return err
 	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
 
 	return value, err
 }
@@ -58,15 +80,17 @@ func (s *BadgerStore) Delete(key string) error {
 }
 
 func (s *BadgerStore) Close() error {
+	s.stopMaintenance()
 	return s.db.Close()
 }
 
-func (s *BadgerStore) ListKeys() ([]string, error) {
+func (s *BadgerStore) ListKeys(prefix string) ([]string, error) {
 	var keys []string
 
 	err := s.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
+		opts.Prefix = []byte(prefix)
 
 		it := txn.NewIterator(opts)
 		defer it.Close()
@@ -83,36 +107,132 @@ func (s *BadgerStore) ListKeys() ([]string, error) {
 	return keys, err
 }
 
+var ErrNoteNotFound = fmt.Errorf("note %w", ErrNotFound)
+
 type Note struct {
 	ID       string                 `json:"id"`
 	Content  string                 `json:"content"`
 	Metadata map[string]interface{} `json:"metadata"`
+	// Slug is the note's URL, derived when the note is published; any
+	// slug sent by clients is ignored.
+	Slug string `json:"slug,omitempty"`
+	// Derived is computed from the content whenever the note is stored.
+	Derived DerivedMetadata `json:"derived"`
 }
 
 type NoteStore struct {
-	store Store
+	store      Store
+	access     *AccessStore
+	schedule   *ScheduleStore
+	hardExpiry bool
+	schema     *MetadataSchema
+	now        func() time.Time
 }
 
 func NewNoteStore(store Store) *NoteStore {
-	return &NoteStore{store: store}
+	return &NoteStore{
+		store:    store,
+		access:   NewAccessStore(store),
+		schedule: NewScheduleStore(store),
+		schema:   DefaultMetadataSchema,
+		now:      time.Now,
+	}
+}
+
+// SetHardExpiry makes DeleteExpiredNotes delete notes whose expire_at has
+// passed, and SaveNote reject them. Otherwise expired notes are kept and
+// only hidden.
+func (ns *NoteStore) SetHardExpiry(enabled bool) {
+	ns.hardExpiry = enabled
+}
+
+// SetMetadataSchema sets the schema note metadata is coerced to and
+// validated against on save, DefaultMetadataSchema unless set.
+func (ns *NoteStore) SetMetadataSchema(schema *MetadataSchema) {
+	ns.schema = schema
 }
 
+// SaveNote stores a note after extracting its frontmatter and validating
+// it against the metadata schema, see MetadataSchema.ValidateNote;
+// frontmatter that does not decode is a violation too. A "password"
+// metadata field is never stored with the note: it becomes the note's own
+// password in the access store, once the note has passed validation. The note is given a slug, see
+// assignSlug, and its aliases are indexed as redirects to it. Under hard
+// expiry a note whose expire_at has passed is rejected.
 func (ns *NoteStore) SaveNote(note Note) error {
-	ExtractFrontmatter(&note)
+	if err := ns.schema.ExtractFrontmatter(&note); err != nil {
+		violations := append(ValidateNoteID(note.ID), FrontmatterViolation(err))
+		return &ValidationError{Violations: violations}
+	}
+
+	if err := ns.schema.ValidateNote(note); err != nil {
+		return err
+	}
+	// Under hard expiry a note that has already expired would only be
+	// deleted again; reject it before anything is stored.
+	if ns.hardExpiry && note.Expired(ns.now()) {
+		return &ValidationError{Violations: []Violation{{Field: "metadata.expire_at", Message: "is in the past"}}}
+	}
+
+	password, protected := note.Metadata["password"]
+	delete(note.Metadata, "password")
+
+	previous, err := ns.GetNote(note.ID)
+	if err != nil && !errors.Is(err, ErrNoteNotFound) {
+		return err
+	}
+	if err := ns.assignSlug(&note, previous); err != nil {
+		return err
+	}
+	// The password is set before the note is written and removed after,
+	// so that a protected note is never readable without one.
+	if protected {
+		if err := ns.access.SetNotePassword(note.ID, fmt.Sprint(password)); err != nil {
+			return err
+		}
+	}
+	if err := ns.put(note); err != nil {
+		return err
+	}
+	if err := ns.indexAliases(previous, note); err != nil {
+		return err
+	}
+	if err := ns.indexTags(previous, note); err != nil {
+		return err
+	}
+	if !protected {
+		return ns.access.DeleteNotePassword(note.ID)
+	}
+	return nil
+}
 
+// put writes an already extracted note, indexes its tasks and schedules
+// its publish and expiry events.
+func (ns *NoteStore) put(note Note) error {
+	note.Derived = DeriveMetadata(note)
 	data, err := json.Marshal(note)
 	if err != nil {
 		return err
 	}
 
-	return ns.store.Set(note.ID, data)
+	if err := ns.indexTasks(note); err != nil {
+		return err
+	}
+
+	if err := ns.schedule.ScheduleNote(note, ns.now()); err != nil {
+		return err
+	}
+
+	return ns.store.Set(noteKey(note.ID), data)
 }
 
 func (ns *NoteStore) GetNote(id string) (Note, error) {
 	var note Note
 
-	data, err := ns.store.Get(id)
-	if err != nil {
+	data, err := ns.store.Get(noteKey(id))
+	if errors.Is(err, ErrNotFound) {
+		return note, ErrNoteNotFound
+	} else if err != nil {
 		return note, err
 	}
 
@@ -121,17 +241,62 @@ func (ns *NoteStore) GetNote(id string) (Note, error) {
 }
 
 func (ns *NoteStore) DeleteNote(id string) error {
-	return ns.store.Delete(id)
+	if note, err := ns.GetNote(id); err == nil {
+		if err := ns.unindexAliases(note); err != nil {
+			return err
+		}
+		if err := ns.unindexTags(note); err != nil {
+			return err
+		}
+		if err := ns.releaseSlug(note); err != nil {
+			return err
+		}
+	}
+	if err := ns.access.DeleteNotePassword(id); err != nil {
+		return err
+	}
+	if err := ns.schedule.DeleteEntry(id); err != nil {
+		return err
+	}
+	if err := ns.store.Delete(taskKeyPrefix + id); err != nil {
+		return err
+	}
+	return ns.store.Delete(noteKey(id))
+}
+
+// DeleteExpiredNotes deletes the notes whose expire_at has passed, with
+// their indexes, under hard expiry, and reports how many it deleted.
+func (ns *NoteStore) DeleteExpiredNotes() (int, error) {
+	if !ns.hardExpiry {
+		return 0, nil
+	}
+	notes, err := ns.ListNotes()
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, note := range notes {
+		if !note.Expired(ns.now()) {
+			continue
+		}
+		if err := ns.DeleteNote(note.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
 }
+
 func (ns *NoteStore) ListNotes() ([]Note, error) {
-	keys, err := ns.store.ListKeys()
+	ids, err := listIDs(ns.store, noteKeyPrefix)
 	if err != nil {
 		return nil, err
 	}
 
 	var notes []Note
-	for _, key := range keys {
-		note, err := ns.GetNote(key)
+	for _, id := range ids {
+		note, err := ns.GetNote(id)
 		if err != nil {
 			continue
 		}
@@ -34,7 +34,7 @@ func TestBadgerStore(t *testing.T) {
 	if string(retrievedValue) != string(value) {
 		t.Errorf("Expected value %q, got %q", value, retrievedValue)
 	}
-	keys, err := store.ListKeys()
+	keys, err := store.ListKeys("")
 	if err != nil {
 		t.Fatalf("Failed to list keys: %v", err)
 	}
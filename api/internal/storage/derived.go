@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// ReadingWordsPerMinute is the reading speed reading times assume.
+	ReadingWordsPerMinute = 200
+	// ExcerptLength is the longest excerpt, in characters.
+	ExcerptLength = 200
+)
+
+// DerivedMetadata is computed from a note's content whenever it is
+// stored, for listings to show without rendering the note.
+type DerivedMetadata struct {
+	// WordCount and CharCount count the text of the note, Markdown
+	// stripped and code blocks left out. CharCount includes spaces.
+	WordCount int `json:"word_count"`
+	CharCount int `json:"char_count"`
+	// ReadingTime is in whole minutes, at least 1 for any text.
+	ReadingTime int `json:"reading_time"`
+	// Excerpt is the first paragraph as plain text, for notes without a
+	// description.
+	Excerpt string `json:"excerpt,omitempty"`
+	// Image is the URL or vault path of the first image, for thumbnails.
+	Image string `json:"image,omitempty"`
+}
+
+var (
+	markdownImage = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	embeddedImage = regexp.MustCompile(`(?i)!\[\[([^\]|#]+\.(?:png|jpe?g|gif|webp|svg|avif|bmp))(?:[|#][^\]]*)?\]\]`)
+	htmlImage     = regexp.MustCompile(`(?i)<img\s[^>]*src\s*=\s*["']([^"']+)["']`)
+
+	allImages     = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)|!\[\[[^\]]*\]\]`)
+	htmlTag       = regexp.MustCompile(`<[^>]+>`)
+	footnoteRef   = regexp.MustCompile(`\[\^[^\]]*\]`)
+	blockMarker   = regexp.MustCompile(`^ {0,3}(?:>\s?)*(?:#{1,6}\s+|[-*+]\s+(?:\[.\]\s+)?|\d+[.)]\s+(?:\[.\]\s+)?)?`)
+	thematicBreak = regexp.MustCompile(`^ {0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	tableRule     = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?\s*$`)
+	paragraphOnly = regexp.MustCompile(`^ {0,3}(?:[>#|<]|[-*+]\s|\d+[.)]\s)`)
+)
+
+// DeriveMetadata computes the derived metadata of a note whose
+// frontmatter has been extracted.
+func DeriveMetadata(note Note) DerivedMetadata {
+	var derived DerivedMetadata
+
+	var text []string
+	var excerpt []string
+	excerptDone := false
+	fence := ""
+	for _, line := range strings.Split(strings.ReplaceAll(note.Content, "\r\n", "\n"), "\n") {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := codeFence.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			excerptDone = excerptDone || len(excerpt) > 0
+			continue
+		}
+
+		if derived.Image == "" {
+			derived.Image = firstImage(line)
+		}
+
+		if strings.TrimSpace(line) == "" {
+			excerptDone = excerptDone || len(excerpt) > 0
+			continue
+		}
+		if setextH1.MatchString(line) || setextH2.MatchString(line) {
+			// The line before was a heading, not a paragraph.
+			if !excerptDone && len(excerpt) > 0 {
+				excerpt = excerpt[:len(excerpt)-1]
+			}
+			continue
+		}
+		if thematicBreak.MatchString(line) || tableRule.MatchString(line) && strings.Contains(line, "-") {
+			continue
+		}
+
+		plain := stripLine(line)
+		if plain == "" {
+			continue
+		}
+		text = append(text, plain)
+
+		if !excerptDone {
+			if paragraphOnly.MatchString(line) {
+				excerptDone = len(excerpt) > 0
+			} else {
+				excerpt = append(excerpt, plain)
+			}
+		}
+	}
+
+	all := strings.Join(text, " ")
+	derived.WordCount = len(strings.Fields(all))
+	derived.CharCount = utf8.RuneCountInString(all)
+	derived.ReadingTime = (derived.WordCount + ReadingWordsPerMinute - 1) / ReadingWordsPerMinute
+
+	if description, _ := note.Metadata["description"].(string); strings.TrimSpace(description) == "" {
+		derived.Excerpt = truncateWords(strings.Join(excerpt, " "), ExcerptLength)
+	}
+	return derived
+}
+
+// firstImage returns the target of the first image on the line, if any.
+func firstImage(line string) string {
+	first, image := -1, ""
+	for _, pattern := range []*regexp.Regexp{markdownImage, embeddedImage, htmlImage} {
+		if m := pattern.FindStringSubmatchIndex(line); m != nil && (first < 0 || m[0] < first) {
+			first, image = m[0], strings.TrimSpace(line[m[2]:m[3]])
+		}
+	}
+	return image
+}
+
+// stripLine turns one line of Markdown into plain text: block markers,
+// images, HTML tags, footnote references, table pipes and inline markup
+// are dropped and links replaced by their text.
+func stripLine(line string) string {
+	line = blockMarker.ReplaceAllString(line, "")
+	line = allImages.ReplaceAllString(line, "")
+	line = htmlTag.ReplaceAllString(line, "")
+	line = footnoteRef.ReplaceAllString(line, "")
+	line = strings.ReplaceAll(plainText(line), "|", " ")
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// truncateWords shortens text to at most max characters, cutting at a
+// word boundary and adding an ellipsis.
+func truncateWords(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:max-1])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.-") + "…"
+}
+
+// deriveAllMetadata computes the derived metadata of the notes published
+// before it existed.
+func deriveAllMetadata(store Store) error {
+	ns := NewNoteStore(store)
+	notes, err := ns.ListNotes()
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if err := ns.put(note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
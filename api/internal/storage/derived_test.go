@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeriveMetadata(t *testing.T) {
+	content := "Title\n=====\n\n" +
+		"![cover](images/cover.png \"Cover\")\n\n" +
+		"The **first** paragraph links [docs](https://example.com)\n" +
+		"and [[Other Note|another note]].[^1]\n\n" +
+		"```go\nfunc main() {}\n```\n\n" +
+		"- a list item\n" +
+		"| a | b |\n|---|---|\n| c | d |\n\n" +
+		"---\n\n" +
+		"![[later.jpg]]\n"
+
+	got := DeriveMetadata(Note{Content: content})
+	want := DerivedMetadata{
+		// Title, 8 words of the paragraph, 3 of the list and 4 table cells.
+		WordCount:   16,
+		CharCount:   len("Title The first paragraph links docs and another note. a list item a b c d"),
+		ReadingTime: 1,
+		Excerpt:     "The first paragraph links docs and another note.",
+		Image:       "images/cover.png",
+	}
+	if got != want {
+		t.Errorf("DeriveMetadata() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDeriveMetadataExcerpt(t *testing.T) {
+	tests := []struct {
+		name    string
+		note    Note
+		excerpt string
+	}{
+		{"description", Note{Content: "Some text.", Metadata: map[string]interface{}{"description": "Given."}}, ""},
+		{"heading first", Note{Content: "# Heading\nText under it."}, "Text under it."},
+		{"no paragraph", Note{Content: "- only\n- a list"}, ""},
+		{"empty", Note{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeriveMetadata(tt.note).Excerpt; got != tt.excerpt {
+				t.Errorf("Excerpt = %q, want %q", got, tt.excerpt)
+			}
+		})
+	}
+
+	long := strings.Repeat("word ", 100)
+	excerpt := DeriveMetadata(Note{Content: long}).Excerpt
+	if len([]rune(excerpt)) > ExcerptLength || !strings.HasSuffix(excerpt, "word…") {
+		t.Errorf("Expected a truncated excerpt, got %q", excerpt)
+	}
+}
+
+func TestDeriveMetadataReadingTime(t *testing.T) {
+	for words, minutes := range map[int]int{0: 0, 1: 1, 200: 1, 201: 2, 1000: 5} {
+		got := DeriveMetadata(Note{Content: strings.Repeat("word ", words)})
+		if got.WordCount != words || got.ReadingTime != minutes {
+			t.Errorf("Expected %d words to take %d minutes, got %+v", words, minutes, got)
+		}
+	}
+}
+
+func TestSaveNoteDerivesMetadata(t *testing.T) {
+	store := NewMemoryStore()
+	ns := NewNoteStore(store)
+
+	err := ns.SaveNote(Note{ID: "post", Content: "---\ntitle: Post\n---\nOne two three.\n\n<img src=\"a.webp\">"})
+	if err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+	note, err := ns.GetNote("post")
+	if err != nil {
+		t.Fatalf("Failed to get note: %v", err)
+	}
+	if note.Derived.WordCount != 3 || note.Derived.Excerpt != "One two three." || note.Derived.Image != "a.webp" {
+		t.Errorf("Expected derived metadata to be stored, got %+v", note.Derived)
+	}
+}
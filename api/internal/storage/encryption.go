@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ErrWrongEncryptionKey is returned when a Badger database is opened with
+// a key other than the one it is encrypted with, including no key for an
+// encrypted database and a key for an unencrypted one.
+var ErrWrongEncryptionKey = errors.New("encryption key does not match the database")
+
+// BadgerOption configures a BadgerStore.
+type BadgerOption func(*badger.Options)
+
+// WithEncryptionKey encrypts the database with AES, using a 16, 24 or 32
+// byte key for AES-128, AES-192 or AES-256. The key encrypts the data keys
+// Badger encrypts the data with, so it can be changed with RekeyBadger
+// without rewriting the data.
+func WithEncryptionKey(key []byte) BadgerOption {
+	return func(opts *badger.Options) {
+		opts.EncryptionKey = key
+		// Badger needs a block and index cache to read encrypted tables.
+		opts.IndexCacheSize = 100 << 20
+	}
+}
+
+// WithKeyRotation sets how often Badger generates a new data key. Data
+// written before a rotation stays readable with the older data keys.
+func WithKeyRotation(d time.Duration) BadgerOption {
+	return func(opts *badger.Options) {
+		opts.EncryptionKeyRotationDuration = d
+	}
+}
+
+// ParseEncryptionKey decodes a hex encoded encryption key, as printed by
+// `openssl rand -hex 32`.
+func ParseEncryptionKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be hex encoded: %w", err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+	return nil, fmt.Errorf("encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+}
+
+// RekeyBadger re-encrypts the data keys of the Badger database in dataPath
+// with newKey. An empty oldKey rekeys an unencrypted database, which
+// encrypts everything written from then on, and an empty newKey stores the
+// data keys in the clear. The database must not be open.
+func RekeyBadger(dataPath string, oldKey, newKey []byte) error {
+	// Opening the database checks the old key, and fails if a running
+	// server holds the directory lock.
+	store, err := NewBadgerStore(dataPath, WithEncryptionKey(oldKey))
+	if err != nil {
+		return err
+	}
+	if err := store.Close(); err != nil {
+		return err
+	}
+
+	opts := badger.KeyRegistryOptions{
+		Dir:                           dataPath,
+		ReadOnly:                      true,
+		EncryptionKey:                 oldKey,
+		EncryptionKeyRotationDuration: badger.DefaultOptions(dataPath).EncryptionKeyRotationDuration,
+	}
+	registry, err := badger.OpenKeyRegistry(opts)
+	if err != nil {
+		return err
+	}
+
+	opts.EncryptionKey = newKey
+	return badger.WriteKeyRegistry(registry, opts)
+}
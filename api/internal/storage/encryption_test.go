@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBadgerEncryption(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "encryption-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	key := bytes.Repeat([]byte{1}, 32)
+	otherKey := bytes.Repeat([]byte{2}, 32)
+
+	store, err := NewBadgerStore(tempDir, WithEncryptionKey(key))
+	if err != nil {
+		t.Fatalf("Failed to create encrypted BadgerStore: %v", err)
+	}
+	store.Set("note/secret", []byte("sensitive contents"))
+	store.Close()
+
+	err = filepath.WalkDir(tempDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if bytes.Contains(data, []byte("sensitive contents")) {
+			t.Errorf("Expected %s to be encrypted", filepath.Base(path))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to read data files: %v", err)
+	}
+
+	if _, err := NewBadgerStore(tempDir, WithEncryptionKey(otherKey)); !errors.Is(err, ErrWrongEncryptionKey) {
+		t.Errorf("Expected ErrWrongEncryptionKey for another key, got %v", err)
+	}
+	if _, err := NewBadgerStore(tempDir); !errors.Is(err, ErrWrongEncryptionKey) {
+		t.Errorf("Expected ErrWrongEncryptionKey without a key, got %v", err)
+	}
+
+	store, err = NewBadgerStore(tempDir, WithEncryptionKey(key))
+	if err != nil {
+		t.Fatalf("Failed to reopen encrypted BadgerStore: %v", err)
+	}
+	if value, err := store.Get("note/secret"); err != nil || string(value) != "sensitive contents" {
+		t.Errorf("Expected the value back with the right key, got %q, %v", value, err)
+	}
+	store.Close()
+}
+
+func TestRekeyBadger(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "rekey-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	oldKey := bytes.Repeat([]byte{1}, 16)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	store.Set("written-in-the-clear", []byte("1"))
+	store.Close()
+
+	if err := RekeyBadger(tempDir, nil, oldKey); err != nil {
+		t.Fatalf("Failed to encrypt an unencrypted database: %v", err)
+	}
+
+	store, err = NewBadgerStore(tempDir, WithEncryptionKey(oldKey))
+	if err != nil {
+		t.Fatalf("Failed to open with the new key: %v", err)
+	}
+	store.Set("written-encrypted", []byte("2"))
+	store.Close()
+
+	if err := RekeyBadger(tempDir, newKey, oldKey); !errors.Is(err, ErrWrongEncryptionKey) {
+		t.Errorf("Expected rekeying with the wrong key to fail, got %v", err)
+	}
+	if err := RekeyBadger(tempDir, oldKey, newKey); err != nil {
+		t.Fatalf("Failed to rekey: %v", err)
+	}
+
+	if _, err := NewBadgerStore(tempDir, WithEncryptionKey(oldKey)); !errors.Is(err, ErrWrongEncryptionKey) {
+		t.Errorf("Expected the old key to be refused, got %v", err)
+	}
+	store, err = NewBadgerStore(tempDir, WithEncryptionKey(newKey))
+	if err != nil {
+		t.Fatalf("Failed to open with the new key: %v", err)
+	}
+	defer store.Close()
+	for _, key := range []string{"written-in-the-clear", "written-encrypted"} {
+		if _, err := store.Get(key); err != nil {
+			t.Errorf("Expected %q to survive rekeying, got %v", key, err)
+		}
+	}
+}
+
+func TestParseEncryptionKey(t *testing.T) {
+	if key, err := ParseEncryptionKey(strings.Repeat("ab", 32) + "\n"); err != nil || len(key) != 32 {
+		t.Errorf("Expected a 32 byte key, got %d bytes, %v", len(key), err)
+	}
+	for _, invalid := range []string{"not hex", strings.Repeat("ab", 20), ""} {
+		if _, err := ParseEncryptionKey(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileStoreDataDir holds the keys that are not notes, one file per key.
+const fileStoreDataDir = ".publisher"
+
+// fileStoreSlugDir, in fileStoreDataDir, holds the slug of each note,
+// which is not part of its Markdown. Being hidden, it is not listed as
+// keys.
+const fileStoreSlugDir = ".slugs"
+
+var ErrInvalidKey = errors.New("invalid key")
+
+// FileStore keeps notes as Markdown files, <id>.md with the metadata as
+// YAML frontmatter, so the directory can be read and versioned like a
+// vault. Other keys, and the slugs of notes, are kept as files in a
+// hidden directory. Directories starting with a dot, such as .git, are
+// ignored.
+type FileStore struct {
+	root string
+	mu   sync.RWMutex
+}
+
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Join(root, fileStoreDataDir), 0o755); err != nil {
+		return nil, err
+	}
+	store := &FileStore{root: root}
+	if err := startNamespaced(store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// path returns the file holding key, and whether it is a note.
+func (s *FileStore) path(key string) (string, bool, error) {
+	id, isNote := strings.CutPrefix(key, noteKeyPrefix)
+	if !isNote {
+		return filepath.Join(s.root, fileStoreDataDir, url.PathEscape(key)), false, nil
+	}
+
+	for _, segment := range strings.Split(id, "/") {
+		if segment == "" || strings.HasPrefix(segment, ".") || strings.ContainsRune(segment, '\\') {
+			return "", true, fmt.Errorf("%w: %q cannot be stored as a file", ErrInvalidKey, key)
+		}
+	}
+	return filepath.Join(s.root, filepath.FromSlash(id)+".md"), true, nil
+}
+
+// slugPath returns the file holding the slug of the note id.
+func (s *FileStore) slugPath(id string) string {
+	return filepath.Join(s.root, fileStoreDataDir, fileStoreSlugDir, url.PathEscape(id))
+}
+
+func (s *FileStore) Get(key string) ([]byte, error) {
+	path, isNote, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	id := strings.TrimPrefix(key, noteKeyPrefix)
+	s.mu.RLock()
+	data, err := os.ReadFile(path)
+	var slug []byte
+	if err == nil && isNote {
+		slug, err = os.ReadFile(s.slugPath(id))
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	s.mu.RUnlock()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !isNote {
+		return data, nil
+	}
+
+	note := Note{ID: id, Content: string(data), Slug: string(slug)}
+	// A file edited by hand may hold frontmatter that does not decode;
+	// it is served as it is rather than hidden.
+	_ = ExtractFrontmatter(&note)
+	note.Derived = DeriveMetadata(note)
+	return json.Marshal(note)
+}
+
+func (s *FileStore) Set(key string, value []byte) error {
+	path, isNote, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	var note Note
+	if isNote {
+		if err := json.Unmarshal(value, &note); err != nil {
+			return fmt.Errorf("%w: %q must hold a note: %v", ErrInvalidKey, key, err)
+		}
+		if value, err = markdownFile(note); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := writeFile(path, value); err != nil || !isNote {
+		return err
+	}
+	slugPath := s.slugPath(strings.TrimPrefix(key, noteKeyPrefix))
+	if note.Slug == "" {
+		return removeFile(slugPath)
+	}
+	return writeFile(slugPath, []byte(note.Slug))
+}
+
+// writeFile replaces the file at path with data.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it, so readers never see a
+	// partly written file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// markdownFile renders a note as Markdown with YAML frontmatter.
+func markdownFile(note Note) ([]byte, error) {
+	if len(note.Metadata) == 0 {
+		return []byte(note.Content), nil
+	}
+
+	frontmatter, err := yaml.Marshal(note.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(frontmatter)
+	buf.WriteString("---\n")
+	buf.WriteString(note.Content)
+	return buf.Bytes(), nil
+}
+
+func (s *FileStore) Delete(key string) error {
+	path, isNote, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := removeFile(path); err != nil {
+		return err
+	}
+
+	// Remove the note's slug and the folders it leaves empty.
+	if isNote {
+		if err := removeFile(s.slugPath(strings.TrimPrefix(key, noteKeyPrefix))); err != nil {
+			return err
+		}
+		for dir := filepath.Dir(path); dir != s.root; dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// removeFile removes the file at path, if there is one.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+func (s *FileStore) ListKeys(prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+
+	err := filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != s.root {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".md") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, noteKey(strings.TrimSuffix(filepath.ToSlash(rel), ".md")))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.root, fileStoreDataDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if key, err := url.PathUnescape(entry.Name()); err == nil {
+			keys = append(keys, key)
+		}
+	}
+
+	matching := keys[:0]
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			matching = append(matching, key)
+		}
+	}
+	sort.Strings(matching)
+	return matching, nil
+}
@@ -1,44 +1,372 @@
 package storage
 
 import (
-	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v3"
 )
 
-var frontmatterRegex = regexp.MustCompile(`(?s)^---\n(.*?)\n---\n(.*)$`)
+var ErrInvalidFrontmatter = errors.New("invalid frontmatter")
 
-func ParseFrontmatter(content string) (map[string]interface{}, string) {
-	matches := frontmatterRegex.FindStringSubmatch(content)
-	if len(matches) != 3 {
-		return nil, content
+// FrontmatterError is frontmatter that could not be decoded.
+type FrontmatterError struct {
+	// Format is "yaml", "toml" or "json".
+	Format string
+	// Line and Column locate the error in the note's content, counted from
+	// 1. Column is 0 when the decoder does not report one.
+	Line, Column int
+	Err          error
+}
+
+func (e *FrontmatterError) Error() string {
+	return fmt.Sprintf("%v: %s: %s: %v", ErrInvalidFrontmatter, e.Format, e.Position(), e.Err)
+}
+
+func (e *FrontmatterError) Unwrap() []error {
+	return []error{ErrInvalidFrontmatter, e.Err}
+}
+
+// Position describes where the error is, as "line 3" or "line 3,
+// column 7".
+func (e *FrontmatterError) Position() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("line %d", e.Line)
+	}
+	return fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+}
+
+// frontmatterFences are the lines that open a frontmatter block, with the
+// lines that may close it.
+var frontmatterFences = []struct {
+	open   string
+	close  []string
+	format string
+}{
+	{open: "---", close: []string{"---", "..."}, format: "yaml"},
+	{open: "+++", close: []string{"+++"}, format: "toml"},
+	{open: ";;;", close: []string{";;;"}, format: "json"},
+}
+
+// jsonFrontmatterStart matches the first line of a note that opens with
+// a bare JSON object, as Hugo allows.
+var jsonFrontmatterStart = regexp.MustCompile(`^\{\s*(?:"|$)`)
+
+// frontmatterBlock is the frontmatter found at the start of a note.
+type frontmatterBlock struct {
+	format string
+	text   string
+	// line is the line of the content text starts on.
+	line int
+}
+
+// scanFrontmatter finds the frontmatter block opening content, and
+// returns it with the content after it. found is false if content does
+// not open with frontmatter.
+func scanFrontmatter(content string) (block frontmatterBlock, body string, found bool) {
+	first, rest, more := nextLine(content)
+	first = strings.TrimRight(first, " \t")
+
+	for _, fence := range frontmatterFences {
+		if first != fence.open {
+			continue
+		}
+		for remaining := rest; ; {
+			line, after, more := nextLine(remaining)
+			for _, closing := range fence.close {
+				if strings.TrimRight(line, " \t") == closing {
+					text := rest[:len(rest)-len(remaining)]
+					return frontmatterBlock{format: fence.format, text: text, line: 2}, after, true
+				}
+			}
+			if !more {
+				// Without a closing fence, the opening line is a
+				// thematic break rather than frontmatter.
+				return block, content, false
+			}
+			remaining = after
+		}
+	}
+
+	// A bare JSON object ends with a "}" line, unless it fits on one line.
+	if jsonFrontmatterStart.MatchString(first) {
+		if strings.HasSuffix(first, "}") {
+			return frontmatterBlock{format: "json", text: first, line: 1}, rest, true
+		}
+		for remaining := rest; more; {
+			var line, after string
+			line, after, more = nextLine(remaining)
+			if strings.TrimRight(line, " \t") == "}" {
+				text := content[:len(content)-len(after)]
+				return frontmatterBlock{format: "json", text: text, line: 1}, after, true
+			}
+			remaining = after
+		}
 	}
 
-	frontmatterYAML := matches[1]
-	remainingContent := matches[2]
+	return block, content, false
+}
+
+// ParseFrontmatter splits content into its frontmatter and the Markdown
+// after it. Frontmatter is YAML between "---" lines (the closing one may
+// be "..."), TOML between "+++" lines, or JSON between ";;;" lines or as
+// an object opening the note, closed by a "}" line. A byte order mark and
+// CRLF line endings are accepted, and the frontmatter may be empty or the
+// content after it missing. Content without frontmatter is returned as it
+// is, with a nil map; frontmatter that cannot be decoded is returned as a
+// *FrontmatterError along with the content.
+func ParseFrontmatter(content string) (map[string]interface{}, string, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	block, body, found := scanFrontmatter(content)
+	if !found {
+		return nil, content, nil
+	}
 
+	frontmatter, err := decodeFrontmatter(block)
+	if err != nil {
+		return nil, content, err
+	}
+	return frontmatter, strings.TrimSpace(body), nil
+}
+
+// DropFrontmatter returns content without the frontmatter opening it,
+// whether or not the frontmatter decodes.
+func DropFrontmatter(content string) string {
+	content = strings.TrimPrefix(content, "\ufeff")
+	if _, body, found := scanFrontmatter(content); found {
+		return strings.TrimSpace(body)
+	}
+	return content
+}
+
+// decodeFrontmatter decodes a frontmatter block, which must hold a
+// mapping. Empty frontmatter decodes to an empty map.
+func decodeFrontmatter(block frontmatterBlock) (map[string]interface{}, error) {
 	var frontmatter map[string]interface{}
-	decoder := yaml.NewDecoder(bytes.NewBufferString(frontmatterYAML))
-	err := decoder.Decode(&frontmatter)
+	var err error
+	switch block.format {
+	case "yaml":
+		frontmatter, err = decodeYAML(block.text)
+	case "toml":
+		frontmatter, err = decodeTOML(block.text)
+	case "json":
+		if strings.TrimSpace(block.text) != "" {
+			err = json.Unmarshal([]byte(block.text), &frontmatter)
+		}
+	}
+	if err == nil && !isFiniteValue(frontmatter) {
+		err = nonFiniteError(block)
+	}
 	if err != nil {
-		return nil, content
+		return nil, frontmatterError(block, err)
 	}
 
-	return frontmatter, strings.TrimSpace(remainingContent)
+	if frontmatter == nil {
+		frontmatter = map[string]interface{}{}
+	}
+	return frontmatter, nil
+}
+
+// frontmatterValueError is a decoded value that frontmatter cannot hold,
+// at a line and column of the frontmatter block, both counted from 1.
+// Column is 0 when it is not known.
+type frontmatterValueError struct {
+	Line, Column int
+	Message      string
+}
+
+func (e *frontmatterValueError) Error() string {
+	return e.Message
+}
+
+// isFiniteValue reports whether value holds no infinite or NaN number,
+// which metadata cannot hold as it is stored as JSON.
+func isFiniteValue(value interface{}) bool {
+	switch v := value.(type) {
+	case float64:
+		return !math.IsInf(v, 0) && !math.IsNaN(v)
+	case []interface{}:
+		for _, item := range v {
+			if !isFiniteValue(item) {
+				return false
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if !isFiniteValue(item) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
-func ExtractFrontmatter(note *Note) {
-	frontmatter, content := ParseFrontmatter(note.Content)
-	if frontmatter != nil {
-		if note.Metadata == nil {
-			note.Metadata = make(map[string]interface{})
+// tomlNonFinite matches the inf and nan literals of TOML where a value is
+// expected.
+var tomlNonFinite = regexp.MustCompile(`(?:^|[=\[,{])[ \t\r\n]*([+-]?(?:inf|nan))\b`)
+
+// nonFiniteError locates the first infinite or NaN number written in
+// block. JSON has no way to write one.
+func nonFiniteError(block frontmatterBlock) error {
+	err := &frontmatterValueError{Line: 1, Message: "numbers must be finite"}
+	switch block.format {
+	case "yaml":
+		var document yaml.Node
+		if yaml.Unmarshal([]byte(block.text), &document) == nil {
+			if node := yamlNonFinite(&document); node != nil {
+				err.Line, err.Column = node.Line, node.Column
+				err.Message = node.Value + " is not a finite number"
+			}
 		}
+	case "toml":
+		if match := tomlNonFinite.FindStringSubmatchIndex(block.text); match != nil {
+			err.Line, err.Column = textPosition(block.text, match[2])
+			err.Message = block.text[match[2]:match[3]] + " is not a finite number"
+		}
+	}
+	return err
+}
 
-		for key, value := range frontmatter {
-			note.Metadata[key] = value
+// yamlNonFinite returns the first scalar under node that decodes to an
+// infinite or NaN number, or nil.
+func yamlNonFinite(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.ScalarNode {
+		var f float64
+		if node.ShortTag() == "!!float" && node.Decode(&f) == nil && !isFiniteValue(f) {
+			return node
 		}
-		note.Content = content
+		return nil
+	}
+	for _, child := range node.Content {
+		if found := yamlNonFinite(child); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// frontmatterError locates an error of the decoder of block in the note.
+func frontmatterError(block frontmatterBlock, err error) *FrontmatterError {
+	fe := &FrontmatterError{Format: block.format, Line: 1, Err: err}
+
+	var syntax *tomlSyntaxError
+	var value *frontmatterValueError
+	var jsonSyntax *json.SyntaxError
+	var jsonType *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		fe.Line, fe.Column, fe.Err = syntax.Line, syntax.Column, errors.New(syntax.Message)
+	case errors.As(err, &value):
+		fe.Line, fe.Column, fe.Err = value.Line, value.Column, errors.New(value.Message)
+	case errors.As(err, &jsonSyntax):
+		fe.Line, fe.Column = textPosition(block.text, int(jsonSyntax.Offset)-1)
+	case errors.As(err, &jsonType):
+		fe.Line, fe.Column = textPosition(block.text, int(jsonType.Offset))
+	case block.format == "yaml":
+		fe.Line, fe.Column, fe.Err = yamlErrorPosition(err)
+	}
+
+	fe.Line += block.line - 1
+	return fe
+}
+
+// textPosition returns the line and column of the byte offset in text.
+func textPosition(text string, offset int) (line, column int) {
+	text = text[:min(max(offset, 0), len(text))]
+	line = strings.Count(text, "\n") + 1
+	column = utf8.RuneCountInString(text[strings.LastIndex(text, "\n")+1:]) + 1
+	return line, column
+}
+
+// yamlNodeError is a YAML error found at a node, whose position is known.
+type yamlNodeError struct {
+	node    *yaml.Node
+	message string
+}
+
+func (e *yamlNodeError) Error() string {
+	return e.message
+}
+
+// decodeYAML decodes YAML frontmatter, which must be a mapping.
+func decodeYAML(text string) (map[string]interface{}, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, &yamlNodeError{node: root, message: "frontmatter must be a mapping of fields"}
+	}
+	var frontmatter map[string]interface{}
+	err := root.Decode(&frontmatter)
+	return frontmatter, err
+}
+
+// yamlErrorLine matches the position in the message of a YAML error, as
+// in "yaml: line 3: did not find expected key".
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?(?:unmarshal errors:\s*)?(?:line (\d+): )?`)
+
+// yamlErrorPosition returns the line and column of a YAML error, and the
+// error without them. yaml.v3 only reports the line, or nothing for the
+// first line.
+func yamlErrorPosition(err error) (line, column int, cleaned error) {
+	var nodeErr *yamlNodeError
+	if errors.As(err, &nodeErr) {
+		return nodeErr.node.Line, nodeErr.node.Column, errors.New(nodeErr.message)
+	}
+
+	var typeErr *yaml.TypeError
+	message := err.Error()
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		message = typeErr.Errors[0]
+	}
+
+	line = 1
+	m := yamlErrorLine.FindStringSubmatch(message)
+	if m[1] != "" {
+		line, _ = strconv.Atoi(m[1])
+	}
+	return line, 0, errors.New(strings.TrimSpace(message[len(m[0]):]))
+}
+
+// nextLine returns the first line of s without its line ending, and the
+// rest of s after it; more is false if s has a single line.
+func nextLine(s string) (line, rest string, more bool) {
+	line, rest, more = strings.Cut(s, "\n")
+	return strings.TrimSuffix(line, "\r"), rest, more
+}
+
+// ExtractFrontmatter moves the frontmatter of a note's content into its
+// metadata, and coerces the built-in fields to their types, see
+// MetadataSchema. Frontmatter that cannot be decoded is reported as a
+// *FrontmatterError, and the note left as it is.
+func ExtractFrontmatter(note *Note) error {
+	return DefaultMetadataSchema.ExtractFrontmatter(note)
+}
+
+// FrontmatterViolation describes a frontmatter error as a violation of
+// the "frontmatter" field, locating it when err is a *FrontmatterError.
+func FrontmatterViolation(err error) Violation {
+	var fe *FrontmatterError
+	if !errors.As(err, &fe) {
+		return Violation{Field: "frontmatter", Message: err.Error(), err: err}
+	}
+	return Violation{
+		Field:   "frontmatter",
+		Message: fe.Format + ": " + fe.Err.Error(),
+		Line:    fe.Line,
+		Column:  fe.Column,
+		err:     err,
 	}
 }
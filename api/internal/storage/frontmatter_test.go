@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -11,6 +12,7 @@ func TestParseFrontmatter(t *testing.T) {
 		content         string
 		wantFrontmatter map[string]interface{}
 		wantContent     string
+		wantErr         bool
 	}{
 		{
 			name: "Valid frontmatter",
@@ -51,12 +53,91 @@ invalid: yaml: [
 # Content with invalid frontmatter`,
 			wantFrontmatter: nil,
 			wantContent:     "---\ninvalid: yaml: [\n---\n# Content with invalid frontmatter",
+			wantErr:         true,
+		},
+		{
+			name:            "CRLF line endings",
+			content:         "---\r\ntitle: Windows\r\n---\r\n# Body\r\n",
+			wantFrontmatter: map[string]interface{}{"title": "Windows"},
+			wantContent:     "# Body",
+		},
+		{
+			name:            "Byte order mark",
+			content:         "\ufeff---\ntitle: BOM\n---\nBody",
+			wantFrontmatter: map[string]interface{}{"title": "BOM"},
+			wantContent:     "Body",
+		},
+		{
+			name:            "Empty frontmatter",
+			content:         "---\n---\nBody",
+			wantFrontmatter: map[string]interface{}{},
+			wantContent:     "Body",
+		},
+		{
+			name:            "No body",
+			content:         "---\ntitle: Only metadata\n---",
+			wantFrontmatter: map[string]interface{}{"title": "Only metadata"},
+			wantContent:     "",
+		},
+		{
+			name:            "YAML document end",
+			content:         "---\ntitle: Dots\n...\nBody",
+			wantFrontmatter: map[string]interface{}{"title": "Dots"},
+			wantContent:     "Body",
+		},
+		{
+			name:            "TOML",
+			content:         "+++\ntitle = \"TOML\"\ntags = [\"a\", \"b\"]\n+++\nBody",
+			wantFrontmatter: map[string]interface{}{"title": "TOML", "tags": []interface{}{"a", "b"}},
+			wantContent:     "Body",
+		},
+		{
+			name:            "JSON between fences",
+			content:         ";;;\n{\"title\": \"JSON\"}\n;;;\nBody",
+			wantFrontmatter: map[string]interface{}{"title": "JSON"},
+			wantContent:     "Body",
+		},
+		{
+			name:            "JSON object",
+			content:         "{\n  \"title\": \"JSON\",\n  \"tags\": [\"a\"]\n}\nBody",
+			wantFrontmatter: map[string]interface{}{"title": "JSON", "tags": []interface{}{"a"}},
+			wantContent:     "Body",
+		},
+		{
+			name:            "Unclosed fence",
+			content:         "---\nA thematic break, not frontmatter.",
+			wantFrontmatter: nil,
+			wantContent:     "---\nA thematic break, not frontmatter.",
+		},
+		{
+			name:            "YAML that is not a mapping",
+			content:         "---\n- a\n- b\n---\nBody",
+			wantFrontmatter: nil,
+			wantContent:     "---\n- a\n- b\n---\nBody",
+			wantErr:         true,
+		},
+		{
+			name:            "Invalid TOML",
+			content:         "+++\ntitle = \n+++\nBody",
+			wantFrontmatter: nil,
+			wantContent:     "+++\ntitle = \n+++\nBody",
+			wantErr:         true,
+		},
+		{
+			name:            "Invalid JSON",
+			content:         "{\n  \"title\": \n}\nBody",
+			wantFrontmatter: nil,
+			wantContent:     "{\n  \"title\": \n}\nBody",
+			wantErr:         true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotFrontmatter, gotContent := ParseFrontmatter(tt.content)
+			gotFrontmatter, gotContent, err := ParseFrontmatter(tt.content)
+			if tt.wantErr != errors.Is(err, ErrInvalidFrontmatter) {
+				t.Errorf("ParseFrontmatter() error = %v, want error %v", err, tt.wantErr)
+			}
 
 			if !reflect.DeepEqual(gotFrontmatter, tt.wantFrontmatter) {
 				t.Errorf("ParseFrontmatter() frontmatter = %v, want %v", gotFrontmatter, tt.wantFrontmatter)
@@ -74,6 +155,7 @@ func TestExtractFrontmatter(t *testing.T) {
 		note         Note
 		wantMetadata map[string]interface{}
 		wantContent  string
+		wantErr      bool
 	}{
 		{
 			name: "Note with frontmatter",
@@ -122,12 +204,25 @@ title: Test Note
 			},
 			wantContent: "# Content",
 		},
+		{
+			name: "Note with invalid frontmatter",
+			note: Note{
+				ID:       "test-note",
+				Content:  "---\ntitle: [\n---\n# Content",
+				Metadata: map[string]interface{}{"existing": "metadata"},
+			},
+			wantMetadata: map[string]interface{}{"existing": "metadata"},
+			wantContent:  "---\ntitle: [\n---\n# Content",
+			wantErr:      true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			note := tt.note
-			ExtractFrontmatter(&note)
+			if err := ExtractFrontmatter(&note); (err != nil) != tt.wantErr {
+				t.Errorf("ExtractFrontmatter() error = %v, want error %v", err, tt.wantErr)
+			}
 
 			if !reflect.DeepEqual(note.Metadata, tt.wantMetadata) {
 				t.Errorf("ExtractFrontmatter() metadata = %v, want %v", note.Metadata, tt.wantMetadata)
@@ -138,3 +233,65 @@ title: Test Note
 		})
 	}
 }
+
+func TestSaveNoteRejectsInvalidFrontmatter(t *testing.T) {
+	ns := NewNoteStore(NewMemoryStore())
+
+	err := ns.SaveNote(Note{ID: "broken", Content: "---\ntitle: [\n---\nBody"})
+	var validation *ValidationError
+	if !errors.As(err, &validation) || !errors.Is(err, ErrInvalidFrontmatter) {
+		t.Fatalf("Expected a validation error for invalid frontmatter, got %v", err)
+	}
+	if len(validation.Violations) != 1 || validation.Violations[0].Field != "frontmatter" {
+		t.Errorf("Expected a frontmatter violation, got %+v", validation.Violations)
+	}
+	if _, err := ns.GetNote("broken"); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected the note not to be saved, got %v", err)
+	}
+}
+
+func TestFrontmatterErrorPosition(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		format       string
+		line, column int
+	}{
+		{"yaml", "---\ntitle: x\n  bad: indent\n---\nBody", "yaml", 3, 0},
+		{"yaml not a mapping", "---\n\n- a\n---\nBody", "yaml", 3, 1},
+		{"yaml duplicate key", "---\na: 1\na: 2\n---\nBody", "yaml", 3, 0},
+		{"toml", "+++\ntitle = \"x\"\ndraft = maybe\n+++\nBody", "toml", 3, 9},
+		{"json fenced", ";;;\n{\n  \"a\": 1,\n  \"b\" 2\n}\n;;;\nBody", "json", 4, 7},
+		{"json object", "{\n  \"a\": tru\n}\nBody", "json", 2, 11},
+		{"crlf", "\ufeff---\r\ntitle: x\r\n  bad: indent\r\n---\r\nBody", "yaml", 3, 0},
+		{"yaml infinity", "---\ntitle: x\nratio: [1.5, .inf]\n---\nBody", "yaml", 3, 14},
+		{"yaml nan", "---\nnested:\n  value: .NaN\n---\nBody", "yaml", 3, 10},
+		{"toml infinity", "+++\na = 'inf'\nb = [\n  1.5,\n  -inf,\n]\n+++\nBody", "toml", 5, 3},
+		{"toml nan", "+++\na = { b = nan }\n+++\nBody", "toml", 2, 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseFrontmatter(tt.content)
+			var fe *FrontmatterError
+			if !errors.As(err, &fe) {
+				t.Fatalf("Expected a *FrontmatterError, got %v", err)
+			}
+			if fe.Format != tt.format || fe.Line != tt.line || fe.Column != tt.column {
+				t.Errorf("Expected a %s error at %d:%d, got %s at %s", tt.format, tt.line, tt.column, fe.Format, fe.Position())
+			}
+		})
+	}
+}
+
+func TestDropFrontmatter(t *testing.T) {
+	for content, want := range map[string]string{
+		"---\ntitle: [\n---\n\nBody": "Body",
+		"+++\ntitle =\n+++\nBody":    "Body",
+		"No frontmatter":             "No frontmatter",
+		"---\nunclosed":              "---\nunclosed",
+	} {
+		if got := DropFrontmatter(content); got != want {
+			t.Errorf("DropFrontmatter(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
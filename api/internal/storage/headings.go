@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Heading is an entry of a note's table of contents.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	// Anchor is the ID of the heading in the rendered note, see
+	// HeadingAnchor.
+	Anchor string `json:"anchor"`
+}
+
+var (
+	atxHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextH1      = regexp.MustCompile(`^ {0,3}=+[ \t]*$`)
+	setextH2      = regexp.MustCompile(`^ {0,3}-+[ \t]*$`)
+	codeFence     = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	paragraphLine = regexp.MustCompile(`^ {0,3}[^\s>#|*+-]`)
+
+	inlineImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	inlineLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	inlineWikiLink = regexp.MustCompile(`!?\[\[([^\]|]*?)(?:\|([^\]]*))?\]\]`)
+	inlineMarkup   = regexp.MustCompile("[*_`~]|==")
+)
+
+// Headings returns the table of contents of Markdown content: its ATX
+// ("## Title") and setext headings, outside code blocks, as plain text
+// with unique anchors.
+func Headings(content string) []Heading {
+	headings, _ := headingLines(content)
+	return headings
+}
+
+// headingLines returns the headings of content along with the index of
+// the line each ends on.
+func headingLines(content string) (headings []Heading, lines []int) {
+	headings = []Heading{}
+	anchors := headingAnchors{}
+
+	fence := ""
+	previous := ""
+	for i, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			previous = ""
+			continue
+		}
+		if m := codeFence.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			previous = ""
+			continue
+		}
+
+		level := 0
+		text := ""
+		if m := atxHeading.FindStringSubmatch(line); m != nil {
+			level, text = len(m[1]), m[2]
+		} else if previous != "" && setextH1.MatchString(line) {
+			level, text = 1, previous
+		} else if previous != "" && setextH2.MatchString(line) {
+			level, text = 2, previous
+		}
+
+		if level == 0 {
+			if paragraphLine.MatchString(line) {
+				previous = strings.TrimSpace(line)
+			} else {
+				previous = ""
+			}
+			continue
+		}
+
+		previous = ""
+		text = plainText(text)
+		headings = append(headings, Heading{Level: level, Text: text, Anchor: anchors.next(text)})
+		lines = append(lines, i)
+	}
+	return headings, lines
+}
+
+// plainText strips inline Markdown from a heading.
+func plainText(text string) string {
+	text = inlineImage.ReplaceAllString(text, "$1")
+	text = inlineLink.ReplaceAllString(text, "$1")
+	text = inlineWikiLink.ReplaceAllStringFunc(text, func(link string) string {
+		m := inlineWikiLink.FindStringSubmatch(link)
+		if m[2] != "" {
+			return m[2]
+		}
+		return m[1]
+	})
+	text = inlineMarkup.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
+
+// HeadingAnchor turns heading text into an anchor the way GitHub does:
+// lowercased, with spaces turned into dashes and punctuation other than
+// "-" and "_" dropped. Letters outside ASCII are kept, so the anchors of
+// [[Note#Heading]] links can be derived from the heading text alone.
+func HeadingAnchor(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == ' ':
+			b.WriteByte('-')
+		case r == '-', r == '_',
+			unicode.IsLetter(r), unicode.IsMark(r), unicode.IsNumber(r), unicode.Is(unicode.Pc, r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// headingAnchors hands out unique anchors within a note, as GitHub does:
+// repeated headings get "-1", "-2" and so on added.
+type headingAnchors map[string]int
+
+func (seen headingAnchors) next(text string) string {
+	base := HeadingAnchor(text)
+	anchor := base
+	for {
+		if _, taken := seen[anchor]; !taken {
+			break
+		}
+		seen[base]++
+		anchor = base + "-" + strconv.Itoa(seen[base])
+	}
+	seen[anchor] = 0
+	return anchor
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeadings(t *testing.T) {
+	content := "# Getting Started\n" +
+		"Intro text.\n\n" +
+		"## Install *the* `cli` ##\n" +
+		"```bash\n# not a heading\n```\n" +
+		"## Install the cli\n" +
+		"Setext Title\n" +
+		"------------\n\n" +
+		"---\n\n" +
+		"### See [[Other Note|other]] & [docs](https://example.com)\n" +
+		"#### Überblick: Fragen?\n" +
+		"####### too deep\n" +
+		"#hashtag\n"
+
+	want := []Heading{
+		{Level: 1, Text: "Getting Started", Anchor: "getting-started"},
+		{Level: 2, Text: "Install the cli", Anchor: "install-the-cli"},
+		{Level: 2, Text: "Install the cli", Anchor: "install-the-cli-1"},
+		{Level: 2, Text: "Setext Title", Anchor: "setext-title"},
+		{Level: 3, Text: "See other & docs", Anchor: "see-other--docs"},
+		{Level: 4, Text: "Überblick: Fragen?", Anchor: "überblick-fragen"},
+	}
+	if got := Headings(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("Headings() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHeadingAnchorsAreUnique(t *testing.T) {
+	anchors := headingAnchors{}
+	var got []string
+	for _, text := range []string{"Notes", "Notes", "Notes 1", "Notes"} {
+		got = append(got, anchors.next(text))
+	}
+	want := []string{"notes", "notes-1", "notes-1-1", "notes-2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected anchors %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import "strings"
+
+// The store is divided into namespaces by key prefix, so notes never mix
+// with other data:
+//
+//	note/<id>     notes
+//	idx/<name>/   secondary indexes over notes
+//	meta/         schema version and migration state
+//	sys/          API keys, passwords, preview links, schedule and trash
+const (
+	noteKeyPrefix   = "note/"
+	indexKeyPrefix  = "idx/"
+	metaKeyPrefix   = "meta/"
+	systemKeyPrefix = "sys/"
+)
+
+func noteKey(id string) string {
+	return noteKeyPrefix + id
+}
+
+// listIDs returns the keys under prefix with the prefix removed.
+func listIDs(store Store, prefix string) ([]string, error) {
+	keys, err := store.ListKeys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, strings.TrimPrefix(key, prefix))
+	}
+	return ids, nil
+}
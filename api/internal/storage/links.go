@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"regexp"
+	"strings"
+)
+
+// wikiLink matches an Obsidian wikilink or embed: [[target]],
+// [[target#heading]], [[target|label]] or ![[target]].
+var wikiLink = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+
+// linkMatcher finds the wikilinks pointing at one note. Links name a note
+// by its ID or by its last segment alone when no other note has the same
+// one, as Obsidian writes them, with or without a .md extension.
+type linkMatcher struct {
+	id string
+	// name is the last segment of id, "" if it is ambiguous.
+	name string
+}
+
+func newLinkMatcher(id string, notes []Note) linkMatcher {
+	id = linkName(id)
+	_, name := folderOf(id)
+	for _, note := range notes {
+		if _, other := folderOf(linkName(note.ID)); other == name && linkName(note.ID) != id {
+			name = ""
+			break
+		}
+	}
+	return linkMatcher{id: id, name: name}
+}
+
+// linkName is the name a link uses for the note id.
+func linkName(id string) string {
+	return strings.TrimSuffix(NormalizeNoteID(id), ".md")
+}
+
+func (m linkMatcher) matches(target string) bool {
+	target = linkName(target)
+	return target == m.id || (m.name != "" && target == m.name)
+}
+
+// rewrite points the links to the note at the ID to instead, keeping their
+// heading and label. It reports whether any link was found.
+func (m linkMatcher) rewrite(content, to string) (string, bool) {
+	found := false
+	content = wikiLink.ReplaceAllStringFunc(content, func(link string) string {
+		inner := link[2 : len(link)-2]
+		end := strings.IndexAny(inner, "#|")
+		if end < 0 {
+			end = len(inner)
+		}
+		// In tables the label separator is escaped as \|.
+		if end > 0 && inner[end-1] == '\\' {
+			end--
+		}
+
+		if !m.matches(inner[:end]) {
+			return link
+		}
+		found = true
+		return "[[" + linkName(to) + inner[end:] + "]]"
+	})
+	return content, found
+}
+
+// folderOf splits a note ID into its folder and last segment.
+func folderOf(id string) (dir, name string) {
+	i := strings.LastIndex(id, "/")
+	if i < 0 {
+		return "", id
+	}
+	return id[:i], id[i+1:]
+}
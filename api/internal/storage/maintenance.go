@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// MaintenanceOptions configures the value-log GC loop started by
+// StartMaintenance. Zero fields take the defaults.
+type MaintenanceOptions struct {
+	// Interval between GC runs, 10 minutes by default.
+	Interval time.Duration
+	// MaxInterval caps the backoff after runs that reclaim nothing or
+	// fail, 6 hours by default. The interval doubles after each such run
+	// and returns to Interval once a run reclaims space.
+	MaxInterval time.Duration
+	// DiscardRatio is the share of a value-log file that must be garbage
+	// before it is rewritten, 0.5 by default.
+	DiscardRatio float64
+	// OnGC, if set, is called after every run.
+	OnGC func(GCResult)
+}
+
+// GCResult describes one value-log GC run.
+type GCResult struct {
+	// Rewrites is the number of value-log files rewritten.
+	Rewrites int
+	// Reclaimed is the number of bytes the value log shrank by.
+	Reclaimed int64
+	Duration  time.Duration
+	Err       error
+}
+
+// GCStats summarises the value-log GC runs since the store was opened.
+type GCStats struct {
+	Runs           int       `json:"runs"`
+	Rewrites       int       `json:"rewrites"`
+	ReclaimedBytes int64     `json:"reclaimed_bytes"`
+	LastRun        time.Time `json:"last_run,omitzero"`
+	LastError      string    `json:"last_error,omitempty"`
+	NextRun        time.Time `json:"next_run,omitzero"`
+}
+
+// LevelStats describes one level of the LSM tree.
+type LevelStats struct {
+	Level      int   `json:"level"`
+	Tables     int   `json:"tables"`
+	Size       int64 `json:"size_bytes"`
+	TargetSize int64 `json:"target_size_bytes"`
+}
+
+// BadgerStats reports the size of a Badger database on disk, its LSM tree
+// and its value-log GC.
+type BadgerStats struct {
+	LSMSize      int64        `json:"lsm_size_bytes"`
+	ValueLogSize int64        `json:"vlog_size_bytes"`
+	Levels       []LevelStats `json:"levels"`
+	GC           GCStats      `json:"gc"`
+}
+
+// maintenance is the state of a running maintenance loop.
+type maintenance struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartMaintenance runs value-log GC in the background until the store is
+// closed. Badger compacts the LSM tree on its own, but only GC gives the
+// space of overwritten and deleted values in the value log back.
+func (s *BadgerStore) StartMaintenance(opts MaintenanceOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Minute
+	}
+	if opts.MaxInterval < opts.Interval {
+		opts.MaxInterval = max(6*time.Hour, opts.Interval)
+	}
+	if opts.DiscardRatio <= 0 || opts.DiscardRatio >= 1 {
+		opts.DiscardRatio = 0.5
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maintenance != nil {
+		return
+	}
+	m := &maintenance{stop: make(chan struct{}), done: make(chan struct{})}
+	s.maintenance = m
+
+	go func() {
+		defer close(m.done)
+
+		interval := opts.Interval
+		for {
+			s.setNextRun(time.Now().Add(interval))
+			timer := time.NewTimer(interval)
+			select {
+			case <-m.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			result := s.RunValueLogGC(opts.DiscardRatio)
+			if opts.OnGC != nil {
+				opts.OnGC(result)
+			}
+
+			if result.Err != nil || result.Rewrites == 0 {
+				interval = min(2*interval, opts.MaxInterval)
+			} else {
+				interval = opts.Interval
+			}
+		}
+	}()
+}
+
+// stopMaintenance stops the maintenance loop and waits for a GC run in
+// progress to finish.
+func (s *BadgerStore) stopMaintenance() {
+	s.mu.Lock()
+	m := s.maintenance
+	s.maintenance = nil
+	s.mu.Unlock()
+
+	if m != nil {
+		close(m.stop)
+		<-m.done
+	}
+}
+
+// RunValueLogGC rewrites value-log files until none has discardRatio of
+// garbage left, and records the run in the store's stats.
+func (s *BadgerStore) RunValueLogGC(discardRatio float64) GCResult {
+	start := time.Now()
+	before := s.valueLogSize()
+
+	var result GCResult
+	for {
+		err := s.db.RunValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) {
+			break
+		} else if err != nil {
+			result.Err = err
+			break
+		}
+		result.Rewrites++
+	}
+
+	result.Reclaimed = max(before-s.valueLogSize(), 0)
+	result.Duration = time.Since(start)
+
+	s.mu.Lock()
+	s.gc.Runs++
+	s.gc.Rewrites += result.Rewrites
+	s.gc.ReclaimedBytes += result.Reclaimed
+	s.gc.LastRun = start
+	s.gc.LastError = ""
+	if result.Err != nil {
+		s.gc.LastError = result.Err.Error()
+	}
+	s.mu.Unlock()
+
+	return result
+}
+
+func (s *BadgerStore) setNextRun(next time.Time) {
+	s.mu.Lock()
+	s.gc.NextRun = next
+	s.mu.Unlock()
+}
+
+// Stats reports the database size, its LSM levels and the GC runs so far.
+// Sizes are those of the files on disk.
+func (s *BadgerStore) Stats() BadgerStats {
+	stats := BadgerStats{
+		LSMSize:      filesSize(s.dir, ".sst"),
+		ValueLogSize: s.valueLogSize(),
+		Levels:       []LevelStats{},
+	}
+	for _, level := range s.db.Levels() {
+		stats.Levels = append(stats.Levels, LevelStats{
+			Level:      level.Level,
+			Tables:     level.NumTables,
+			Size:       level.Size,
+			TargetSize: level.TargetSize,
+		})
+	}
+
+	s.mu.Lock()
+	stats.GC = s.gc
+	s.mu.Unlock()
+	return stats
+}
+
+func (s *BadgerStore) valueLogSize() int64 {
+	return filesSize(s.valueDir, ".vlog")
+}
+
+// filesSize sums the sizes of the files in dir with the given extension.
+func filesSize(dir, ext string) int64 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	var size int64
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ext {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// smallValueLog keeps values in small value-log files and compacts level
+// 0 as soon as it has a table, so a test can quickly produce garbage for
+// GC.
+func smallValueLog(opts *badger.Options) {
+	opts.ValueThreshold = 1 << 10
+	opts.ValueLogFileSize = 1 << 20
+	opts.NumLevelZeroTables = 1
+}
+
+func TestBadgerValueLogGC(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "gc-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	value := bytes.Repeat([]byte("x"), 64<<10)
+
+	// Closing the store flushes the overwrites to a level 0 table, and
+	// compacting the second one drops the overwritten versions, which
+	// tells GC what it can discard.
+	var store *BadgerStore
+	for round := 0; round < 2; round++ {
+		store, err = NewBadgerStore(tempDir, smallValueLog)
+		if err != nil {
+			t.Fatalf("Failed to open BadgerStore: %v", err)
+		}
+		for i := 0; i < 50; i++ {
+			if err := store.Set("note/republished", value); err != nil {
+				t.Fatalf("Failed to set value: %v", err)
+			}
+		}
+		store.Close()
+	}
+
+	store, err = NewBadgerStore(tempDir, smallValueLog)
+	if err != nil {
+		t.Fatalf("Failed to reopen BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	before := store.Stats().ValueLogSize
+	var result GCResult
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if result = store.RunValueLogGC(0.5); result.Err != nil || result.Rewrites > 0 {
+			break
+		}
+	}
+	if result.Err != nil {
+		t.Fatalf("GC failed: %v", result.Err)
+	}
+	if result.Rewrites == 0 || result.Reclaimed <= 0 {
+		t.Fatalf("Expected GC to reclaim space, got %+v", result)
+	}
+
+	stats := store.Stats()
+	if stats.ValueLogSize != before-result.Reclaimed {
+		t.Errorf("Expected the value log to shrink from %d by %d, got %d", before, result.Reclaimed, stats.ValueLogSize)
+	}
+	if stats.GC.Runs == 0 || stats.GC.ReclaimedBytes != result.Reclaimed {
+		t.Errorf("Expected the run in the stats, got %+v", stats.GC)
+	}
+	if len(stats.Levels) == 0 {
+		t.Errorf("Expected LSM levels in the stats")
+	}
+	if got, err := store.Get("note/republished"); err != nil || !bytes.Equal(got, value) {
+		t.Errorf("Expected the live value to survive GC, got %d bytes, %v", len(got), err)
+	}
+}
+
+func TestBadgerMaintenanceBackoff(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "maintenance-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+
+	runs := make(chan GCResult, 10)
+	store.StartMaintenance(MaintenanceOptions{
+		Interval:    10 * time.Millisecond,
+		MaxInterval: 40 * time.Millisecond,
+		OnGC:        func(result GCResult) { runs <- result },
+	})
+
+	var last time.Time
+	var gaps []time.Duration
+	for i := 0; i < 4; i++ {
+		select {
+		case result := <-runs:
+			if result.Rewrites != 0 || result.Err != nil {
+				t.Errorf("Expected an empty store to have nothing to collect, got %+v", result)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected GC to run")
+		}
+		if !last.IsZero() {
+			gaps = append(gaps, time.Since(last))
+		}
+		last = time.Now()
+	}
+	if gaps[len(gaps)-1] < 35*time.Millisecond {
+		t.Errorf("Expected runs that reclaim nothing to back off to the max interval, got gaps %v", gaps)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store with maintenance running: %v", err)
+	}
+	select {
+	case result := <-runs:
+		t.Errorf("Expected no GC after closing, got %+v", result)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	schemaVersionKey     = metaKeyPrefix + "schema_version"
+	migrationStatePrefix = metaKeyPrefix + "migration/"
+)
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this server")
+
+// Migration upgrades the store from Version-1 to Version. Migrations run
+// once, in order, when the server starts; each must be safe to run again
+// if the server stops halfway through it. Progress can be kept under
+// migrationState(Version), which is cleared once the version is recorded.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(store Store) error
+}
+
+var migrations = []Migration{
+	{Version: 1, Description: "move notes under note/ and server data under sys/", Up: namespaceKeys},
+	{Version: 2, Description: "index note aliases", Up: indexAllAliases},
+	{Version: 3, Description: "assign note slugs", Up: assignAllSlugs},
+	{Version: 4, Description: "derive note statistics", Up: deriveAllMetadata},
+	{Version: 5, Description: "index note tags", Up: indexAllTags},
+	{Version: 6, Description: "index note tasks", Up: indexAllTasks},
+	{Version: 7, Description: "assign the slugs the file backend did not keep", Up: assignAllSlugs},
+}
+
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the version of the store's layout. Stores written
+// before versioning existed, and empty ones, are at version 0.
+func SchemaVersion(store Store) (int, error) {
+	data, err := store.Get(schemaVersionKey)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q", data)
+	}
+	return version, nil
+}
+
+// startNamespaced marks a store of a backend that never held the
+// unversioned layout as already namespaced, so a directory of existing
+// notes opened as a new store is not moved around by the first migration.
+func startNamespaced(store Store) error {
+	if _, err := store.Get(schemaVersionKey); !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return store.Set(schemaVersionKey, []byte("1"))
+}
+
+// Migrate brings the store up to the latest schema version and returns
+// the versions it started and ended at.
+func Migrate(store Store) (from, to int, err error) {
+	return migrate(store, migrations)
+}
+
+func migrate(store Store, migrations []Migration) (from, to int, err error) {
+	from, err = SchemaVersion(store)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	if from > latest {
+		return from, from, fmt.Errorf("%w: version %d, expected at most %d", ErrSchemaTooNew, from, latest)
+	}
+
+	to = from
+	for _, m := range migrations {
+		if m.Version <= to {
+			continue
+		}
+		if err := m.Up(store); err != nil {
+			return from, to, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		if err := store.Set(schemaVersionKey, []byte(strconv.Itoa(m.Version))); err != nil {
+			return from, to, err
+		}
+		to = m.Version
+
+		state, err := store.ListKeys(migrationState(m.Version))
+		if err != nil {
+			return from, to, err
+		}
+		for _, key := range state {
+			if err := store.Delete(key); err != nil {
+				return from, to, err
+			}
+		}
+	}
+	return from, to, nil
+}
+
+func migrationState(version int) string {
+	return migrationStatePrefix + strconv.Itoa(version) + "/"
+}
+
+// namespaceKeys moves the notes of an unversioned store, which were kept
+// at their raw ID, under note/, and the "_sys/" keys under sys/.
+func namespaceKeys(store Store) error {
+	const legacySystemPrefix = "_sys/"
+	// The keys to move are listed once, then moved one by one while
+	// counting progress, so an interrupted run resumes where it stopped
+	// instead of moving keys twice.
+	keysKey := migrationState(1) + "keys"
+	nextKey := migrationState(1) + "next"
+
+	target := func(key string) string {
+		if strings.HasPrefix(key, legacySystemPrefix) {
+			return systemKeyPrefix + strings.TrimPrefix(key, legacySystemPrefix)
+		}
+		return noteKey(key)
+	}
+
+	var keys []string
+	if data, err := store.Get(keysKey); err == nil {
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return err
+		}
+	} else if errors.Is(err, ErrNotFound) {
+		keys, err = store.ListKeys("")
+		if err != nil {
+			return err
+		}
+		// Notes go first, longest first, so a note is never overwritten
+		// by a shorter one moving onto its ID. System keys follow once no
+		// note is left at a sys/ key.
+		sort.SliceStable(keys, func(i, j int) bool {
+			iSys, jSys := strings.HasPrefix(keys[i], legacySystemPrefix), strings.HasPrefix(keys[j], legacySystemPrefix)
+			if iSys != jSys {
+				return jSys
+			}
+			return len(keys[i]) > len(keys[j])
+		})
+		data, err := json.Marshal(keys)
+		if err != nil {
+			return err
+		}
+		if err := store.Set(keysKey, data); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	next := 0
+	if data, err := store.Get(nextKey); err == nil {
+		if next, err = strconv.Atoi(string(data)); err != nil {
+			return err
+		}
+	}
+
+	for ; next < len(keys); next++ {
+		key := keys[next]
+
+		value, err := store.Get(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := store.Set(target(key), value); err != nil {
+			return err
+		}
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+
+		if err := store.Set(nextKey, []byte(strconv.Itoa(next+1))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// legacyStore writes an unversioned store the way servers did before key
+// namespacing, with notes at their raw ID, and closes it.
+func legacyStore(t *testing.T, dir string) {
+	t.Helper()
+
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	legacy := map[string]string{
+		"welcome":           `{"id":"welcome","content":"hello"}`,
+		"docs/guide":        `{"id":"docs/guide","content":"guide"}`,
+		"note/welcome":      `{"id":"note/welcome","content":"shadowed"}`,
+		"sys/status":        `{"id":"sys/status","content":"up"}`,
+		"_sys/apikey/abc":   `{"id":"abc","name":"ci"}`,
+		"_sys/secret/token": "secret",
+	}
+	for key, value := range legacy {
+		if err := store.Set(key, []byte(value)); err != nil {
+			t.Fatalf("Failed to write legacy key: %v", err)
+		}
+	}
+}
+
+func checkMigrated(t *testing.T, store Store) {
+	t.Helper()
+
+	noteStore := NewNoteStore(store)
+	for id, content := range map[string]string{
+		"welcome":      "hello",
+		"docs/guide":   "guide",
+		"note/welcome": "shadowed",
+		"sys/status":   "up",
+	} {
+		note, err := noteStore.GetNote(id)
+		if err != nil {
+			t.Errorf("Expected note %q after migration, got %v", id, err)
+			continue
+		}
+		if note.Content != content {
+			t.Errorf("Expected note %q to hold %q, got %q", id, content, note.Content)
+		}
+		if note.Derived.WordCount != 1 || note.Derived.Excerpt != content {
+			t.Errorf("Expected note %q to have derived metadata, got %+v", id, note.Derived)
+		}
+	}
+
+	notes, err := noteStore.ListNotes()
+	if err != nil {
+		t.Fatalf("Failed to list notes: %v", err)
+	}
+	if len(notes) != 4 {
+		t.Errorf("Expected 4 notes, got %d", len(notes))
+	}
+
+	if secret, err := store.Get(secretPrefix + "token"); err != nil || string(secret) != "secret" {
+		t.Errorf("Expected secret under sys/, got %q, %v", secret, err)
+	}
+	if _, err := store.Get(apiKeyPrefix + "abc"); err != nil {
+		t.Errorf("Expected API key under sys/, got %v", err)
+	}
+
+	if keys, _ := store.ListKeys("_sys/"); len(keys) != 0 {
+		t.Errorf("Expected no legacy system keys, got %v", keys)
+	}
+	if keys, _ := store.ListKeys(migrationStatePrefix); len(keys) != 0 {
+		t.Errorf("Expected migration state to be cleared, got %v", keys)
+	}
+	if version, err := SchemaVersion(store); err != nil || version != 7 {
+		t.Errorf("Expected schema version 7, got %d, %v", version, err)
+	}
+}
+
+func TestMigrateLegacyStore(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "migrate-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	legacyStore(t, tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen BadgerStore: %v", err)
+	}
+
+	from, to, err := Migrate(store)
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if from != 0 || to != 7 {
+		t.Errorf("Expected migration from 0 to 7, got %d to %d", from, to)
+	}
+	checkMigrated(t, store)
+	store.Close()
+
+	store, err = NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to reopen BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	from, to, err = Migrate(store)
+	if err != nil || from != 7 || to != 7 {
+		t.Errorf("Expected a migrated store to stay at version 7, got %d to %d, %v", from, to, err)
+	}
+	checkMigrated(t, store)
+}
+
+// flakyStore fails every write after the first n.
+type flakyStore struct {
+	Store
+	writes int
+}
+
+var errFlaky = errors.New("disk full")
+
+func (s *flakyStore) Set(key string, value []byte) error {
+	if s.writes == 0 {
+		return errFlaky
+	}
+	s.writes--
+	return s.Store.Set(key, value)
+}
+
+func TestMigrateResumesAfterInterruption(t *testing.T) {
+	for _, writes := range []int{0, 1, 2, 4, 7} {
+		tempDir, err := os.MkdirTemp("", "migrate-resume-test")
+		if err != nil {
+			t.Fatalf("Failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		legacyStore(t, tempDir)
+
+		store, err := NewBadgerStore(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to reopen BadgerStore: %v", err)
+		}
+		if _, _, err := Migrate(&flakyStore{Store: store, writes: writes}); !errors.Is(err, errFlaky) {
+			t.Fatalf("Expected the migration to be interrupted after %d writes, got %v", writes, err)
+		}
+		store.Close()
+
+		store, err = NewBadgerStore(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to reopen BadgerStore: %v", err)
+		}
+		if _, _, err := Migrate(store); err != nil {
+			t.Fatalf("Failed to resume migration after %d writes: %v", writes, err)
+		}
+		checkMigrated(t, store)
+		store.Close()
+	}
+}
+
+func TestMigrateFreshStore(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "migrate-fresh-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	if _, to, err := Migrate(store); err != nil || to != 7 {
+		t.Fatalf("Expected a fresh store to be at version 7, got %d, %v", to, err)
+	}
+	if keys, _ := store.ListKeys(""); len(keys) != 1 || keys[0] != schemaVersionKey {
+		t.Errorf("Expected only the schema version in a fresh store, got %v", keys)
+	}
+
+	store.Set(schemaVersionKey, []byte("99"))
+	if _, _, err := Migrate(store); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Expected a newer schema to be refused, got %v", err)
+	}
+}
+
+func TestMigrateRunsPendingMigrationsInOrder(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "migrate-order-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	var ran []int
+	step := func(version int) Migration {
+		return Migration{Version: version, Up: func(store Store) error {
+			ran = append(ran, version)
+			return store.Set(migrationState(version)+"progress", []byte("done"))
+		}}
+	}
+
+	store.Set(schemaVersionKey, []byte("1"))
+	from, to, err := migrate(store, []Migration{step(1), step(2), step(3)})
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if from != 1 || to != 3 || len(ran) != 2 || ran[0] != 2 || ran[1] != 3 {
+		t.Errorf("Expected migrations 2 and 3 to run, got %v (%d to %d)", ran, from, to)
+	}
+	if keys, _ := store.ListKeys(metaKeyPrefix + "migration/"); len(keys) != 0 {
+		t.Errorf("Expected migration state to be cleared, got %v", keys)
+	}
+}
@@ -0,0 +1,27 @@
+package storage
+
+import "fmt"
+
+const (
+	BackendBadger = "badger"
+	BackendSQLite = "sqlite"
+	BackendFiles  = "files"
+	BackendMemory = "memory"
+)
+
+// OpenStore opens a store with the named backend: a Badger directory, a
+// SQLite database file, a directory of Markdown files, or an in-memory
+// store that ignores path. The options only apply to the Badger backend.
+func OpenStore(backend, path string, options ...BadgerOption) (Store, error) {
+	switch backend {
+	case BackendBadger, "":
+		return NewBadgerStore(path, options...)
+	case BackendSQLite:
+		return NewSQLiteStore(path)
+	case BackendFiles:
+		return NewFileStore(path)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", backend)
+}
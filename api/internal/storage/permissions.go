@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var ErrInvalidIDPattern = errors.New("invalid ID pattern")
+
+// MatchIDPattern reports whether a note ID matches an ID pattern. Patterns
+// are slash-separated: "*" matches within a single segment and "**"
+// matches any number of segments, so "engineering/**" covers every note
+// below engineering/. A pattern without wildcards matches that ID and the
+// IDs below it, so "engineering" covers engineering/deploy but not
+// engineering-secret/deploy.
+func MatchIDPattern(pattern, id string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		pattern = strings.TrimSuffix(pattern, "/")
+		return id == pattern || strings.HasPrefix(id, pattern+"/")
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(id, "/"))
+}
+
+func matchSegments(pattern, id []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(id); i++ {
+				if matchSegments(pattern[1:], id[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(id) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], id[0]); err != nil || !ok {
+			return false
+		}
+		pattern, id = pattern[1:], id[1:]
+	}
+	return len(id) == 0
+}
+
+// ValidateIDPattern checks that a pattern is well formed.
+func ValidateIDPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("%w: empty pattern", ErrInvalidIDPattern)
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment != "**" && strings.Contains(segment, "**") {
+			return fmt.Errorf("%w %q: ** must be a whole path segment", ErrInvalidIDPattern, pattern)
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("%w %q: %v", ErrInvalidIDPattern, pattern, err)
+		}
+	}
+	return nil
+}
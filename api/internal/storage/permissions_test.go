@@ -0,0 +1,51 @@
+package storage
+
+import "testing"
+
+func TestMatchIDPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		id      string
+		want    bool
+	}{
+		{"engineering/**", "engineering/runbooks/deploy", true},
+		{"engineering/**", "engineering", true},
+		{"engineering/**", "engineering-old/deploy", false},
+		{"engineering/**", "design/engineering/deploy", false},
+		{"engineering/*", "engineering/deploy", true},
+		{"engineering/*", "engineering/runbooks/deploy", false},
+		{"**/drafts/*", "design/2024/drafts/logo", true},
+		{"**/drafts/*", "drafts/logo", true},
+		{"design/*.md", "design/logo.md", true},
+		{"docs/", "docs/intro", true},
+		{"docs/", "blog/intro", false},
+		{"engineering", "engineering", true},
+		{"engineering", "engineering/deploy", true},
+		{"engineering", "engineering-secret/deploy", false},
+		{"docs/", "docs-old/intro", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.id, func(t *testing.T) {
+			if got := MatchIDPattern(tt.pattern, tt.id); got != tt.want {
+				t.Errorf("MatchIDPattern(%q, %q) = %v, want %v", tt.pattern, tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateIDPattern(t *testing.T) {
+	valid := []string{"engineering/**", "**/drafts/*", "docs/", "design/[a-c]*"}
+	for _, pattern := range valid {
+		if err := ValidateIDPattern(pattern); err != nil {
+			t.Errorf("ValidateIDPattern(%q) = %v, want nil", pattern, err)
+		}
+	}
+
+	invalid := []string{"", "engineering/a**", "design/[a-"}
+	for _, pattern := range invalid {
+		if err := ValidateIDPattern(pattern); err == nil {
+			t.Errorf("ValidateIDPattern(%q) = nil, want error", pattern)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const previewLinkPrefix = systemKeyPrefix + "preview/"
+
+var ErrPreviewLinkNotFound = fmt.Errorf("preview link %w", ErrNotFound)
+
+// IsDraft reports whether the note is marked "draft: true".
+func (n Note) IsDraft() bool {
+	switch draft := n.Metadata["draft"].(type) {
+	case bool:
+		return draft
+	case string:
+		return strings.EqualFold(draft, "true")
+	}
+	return false
+}
+
+// Revision identifies the stored content and metadata of a note. It
+// changes whenever the note is republished with different data.
+func Revision(note Note) string {
+	data, _ := json.Marshal(struct {
+		Content  string                 `json:"content"`
+		Metadata map[string]interface{} `json:"metadata"`
+	}{note.Content, note.Metadata})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// PreviewLink grants read access to one revision of a draft note until it
+// expires or is revoked.
+type PreviewLink struct {
+	ID        string    `json:"id"`
+	NoteID    string    `json:"note_id"`
+	Revision  string    `json:"revision"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type PreviewStore struct {
+	store Store
+	now   func() time.Time
+}
+
+func NewPreviewStore(store Store) *PreviewStore {
+	return &PreviewStore{store: store, now: time.Now}
+}
+
+func (ps *PreviewStore) CreatePreviewLink(noteID, revision string, expiresAt time.Time) (PreviewLink, error) {
+	id, err := randomString(12, hex.EncodeToString)
+	if err != nil {
+		return PreviewLink{}, err
+	}
+
+	link := PreviewLink{
+		ID:        id,
+		NoteID:    noteID,
+		Revision:  revision,
+		CreatedAt: ps.now().UTC(),
+		ExpiresAt: expiresAt.UTC(),
+	}
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		return PreviewLink{}, err
+	}
+	if err := ps.store.Set(previewLinkPrefix+id, data); err != nil {
+		return PreviewLink{}, err
+	}
+	return link, nil
+}
+
+// GetPreviewLink returns an unexpired, unrevoked link.
+func (ps *PreviewStore) GetPreviewLink(id string) (PreviewLink, error) {
+	var link PreviewLink
+
+	data, err := ps.store.Get(previewLinkPrefix + id)
+	if errors.Is(err, ErrNotFound) {
+		return link, ErrPreviewLinkNotFound
+	} else if err != nil {
+		return link, err
+	}
+	if err := json.Unmarshal(data, &link); err != nil {
+		return link, err
+	}
+	if !ps.now().Before(link.ExpiresAt) {
+		return link, ErrPreviewLinkNotFound
+	}
+	return link, nil
+}
+
+// ListPreviewLinks returns the active links of a note, dropping expired
+// ones from the store as it goes.
+func (ps *PreviewStore) ListPreviewLinks(noteID string) ([]PreviewLink, error) {
+	ids, err := listIDs(ps.store, previewLinkPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	links := []PreviewLink{}
+	for _, id := range ids {
+		link, err := ps.GetPreviewLink(id)
+		if errors.Is(err, ErrPreviewLinkNotFound) {
+			ps.store.Delete(previewLinkPrefix + id)
+			continue
+		}
+		if err != nil || link.NoteID != noteID {
+			continue
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+func (ps *PreviewStore) RevokePreviewLink(id string) error {
+	if _, err := ps.store.Get(previewLinkPrefix + id); err != nil {
+		return ErrPreviewLinkNotFound
+	}
+	return ps.store.Delete(previewLinkPrefix + id)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPreviewStore(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "preview-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	previewStore := NewPreviewStore(store)
+
+	link, err := previewStore.CreatePreviewLink("draft", "rev1", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to create preview link: %v", err)
+	}
+	expired, err := previewStore.CreatePreviewLink("draft", "rev1", time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("Failed to create preview link: %v", err)
+	}
+	if _, err := previewStore.CreatePreviewLink("other", "rev1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to create preview link: %v", err)
+	}
+
+	got, err := previewStore.GetPreviewLink(link.ID)
+	if err != nil {
+		t.Fatalf("Failed to get preview link: %v", err)
+	}
+	if got.NoteID != "draft" || got.Revision != "rev1" {
+		t.Errorf("Unexpected preview link %+v", got)
+	}
+	if _, err := previewStore.GetPreviewLink(expired.ID); !errors.Is(err, ErrPreviewLinkNotFound) {
+		t.Errorf("Expected expired link to be rejected, got %v", err)
+	}
+
+	links, err := previewStore.ListPreviewLinks("draft")
+	if err != nil {
+		t.Fatalf("Failed to list preview links: %v", err)
+	}
+	if len(links) != 1 || links[0].ID != link.ID {
+		t.Errorf("Expected only the active link of the note, got %v", links)
+	}
+
+	if err := previewStore.RevokePreviewLink(link.ID); err != nil {
+		t.Fatalf("Failed to revoke preview link: %v", err)
+	}
+	if _, err := previewStore.GetPreviewLink(link.ID); !errors.Is(err, ErrPreviewLinkNotFound) {
+		t.Errorf("Expected revoked link to be rejected, got %v", err)
+	}
+
+	notes, err := NewNoteStore(store).ListNotes()
+	if err != nil {
+		t.Fatalf("Failed to list notes: %v", err)
+	}
+	if len(notes) != 0 {
+		t.Errorf("Expected preview links to be excluded from notes, got %v", notes)
+	}
+}
+
+func TestRevision(t *testing.T) {
+	note := Note{ID: "a", Content: "one", Metadata: map[string]interface{}{"draft": true}}
+	changed := Note{ID: "a", Content: "two", Metadata: map[string]interface{}{"draft": true}}
+
+	if Revision(note) != Revision(note) {
+		t.Errorf("Expected revision to be stable")
+	}
+	if Revision(note) == Revision(changed) {
+		t.Errorf("Expected revision to change with content")
+	}
+	if !note.IsDraft() {
+		t.Errorf("Expected note to be a draft")
+	}
+}
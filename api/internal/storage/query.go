@@ -0,0 +1,961 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// QueryError is a query that could not be parsed.
+type QueryError struct {
+	// Column is the character of the query the error is at, counted from 1.
+	Column  int
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%v: column %d: %s", ErrInvalidQuery, e.Column, e.Message)
+}
+
+func (e *QueryError) Unwrap() error {
+	return ErrInvalidQuery
+}
+
+// Query selects notes by their metadata, in a small subset of Obsidian's
+// Dataview language:
+//
+//	TABLE status, updated FROM #project AND "Work" WHERE status != "done" SORT updated DESC LIMIT 10
+//
+// Every clause is optional and keywords are case-insensitive. A query
+// starts with LIST, the default, or TABLE and the fields to show, each
+// optionally followed by AS "Label". FROM narrows the notes to #tags,
+// including nested tags, and "folders", combined with AND, OR, NOT or "-"
+// and parentheses. WHERE filters them with comparisons (=, !=, <, <=, >,
+// >=), AND, OR, NOT or "!", parentheses and the functions contains(value,
+// item), startswith(text, prefix), lower(text) and date(value), where
+// date(today), date(now), date(yesterday) and date(tomorrow) are
+// understood. SORT orders them by one or more fields, ASC or DESC, and
+// LIMIT caps their number.
+//
+// Fields name metadata, with dots into nested fields, or the note itself:
+// file.path (the ID, also id), file.name, file.folder, file.tags (tags
+// of the metadata and content), slug, and derived.word_count and the
+// other derived metadata. Comparing a list to a value tests whether the
+// list holds it; dates compare as instants, and notes without a field
+// sort last.
+type Query struct {
+	// Table is set for TABLE queries, Fields holding their columns.
+	Table  bool
+	Fields []QueryField
+	Limit  int
+
+	from  querySource
+	where queryExpr
+	sort  []querySort
+}
+
+// QueryField is a column of a TABLE query.
+type QueryField struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}
+
+type querySort struct {
+	field      string
+	descending bool
+}
+
+// QueryResult is a note matched by a query, with the values of the fields
+// of a TABLE query.
+type QueryResult struct {
+	Note   Note
+	Values []interface{}
+}
+
+// Query returns the notes matching q for which keep returns true, with
+// the values of its fields. Tags and folders in FROM are looked up in
+// the tag index and by note ID, so only those notes are read.
+func (ns *NoteStore) Query(q *Query, keep func(Note) bool) ([]QueryResult, error) {
+	var ids []string
+	if q.from == nil {
+		var err error
+		if ids, err = listIDs(ns.store, noteKeyPrefix); err != nil {
+			return nil, err
+		}
+	} else {
+		set, err := q.from.ids(ns)
+		if err != nil {
+			return nil, err
+		}
+		for id := range set {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	ctx := queryContext{now: ns.now()}
+	var results []QueryResult
+	for _, id := range ids {
+		note, err := ns.GetNote(id)
+		if errors.Is(err, ErrNoteNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ctx.note = note
+		if q.where != nil && !truthy(q.where.eval(ctx)) {
+			continue
+		}
+		if keep != nil && !keep(note) {
+			continue
+		}
+		results = append(results, QueryResult{Note: note})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		for _, key := range q.sort {
+			a := noteField(results[i].Note, key.field)
+			b := noteField(results[j].Note, key.field)
+			// Notes without the field sort last either way.
+			if a == nil || b == nil {
+				if (a == nil) != (b == nil) {
+					return b == nil
+				}
+				continue
+			}
+			c, ok := compareValues(a, b)
+			if !ok || c == 0 {
+				continue
+			}
+			return (c < 0) != key.descending
+		}
+		return false
+	})
+
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	for i := range results {
+		for _, field := range q.Fields {
+			value := noteField(results[i].Note, field.Name)
+			if t, ok := value.(time.Time); ok {
+				value = t.Format(time.RFC3339)
+			}
+			results[i].Values = append(results[i].Values, value)
+		}
+	}
+	return results, nil
+}
+
+// noteField returns the value of a query field of the note, nil if it
+// has none.
+func noteField(note Note, name string) interface{} {
+	switch name {
+	case "id", "file.path":
+		return note.ID
+	case "file.name":
+		_, base := folderOf(note.ID)
+		return strings.TrimSuffix(base, ".md")
+	case "file.folder":
+		dir, _ := folderOf(note.ID)
+		return dir
+	case "file.tags":
+		tags := []interface{}{}
+		for _, tag := range note.Tags() {
+			tags = append(tags, tag)
+		}
+		return tags
+	case "slug":
+		return note.Slug
+	case "derived.word_count":
+		return note.Derived.WordCount
+	case "derived.char_count":
+		return note.Derived.CharCount
+	case "derived.reading_time":
+		return note.Derived.ReadingTime
+	case "derived.excerpt":
+		return note.Derived.Excerpt
+	case "derived.image":
+		return note.Derived.Image
+	}
+
+	if value, ok := note.Metadata[name]; ok {
+		return value
+	}
+	var value interface{} = note.Metadata
+	for _, key := range strings.Split(name, ".") {
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = fields[key]
+	}
+	return value
+}
+
+// querySource is a FROM clause, which resolves to a set of note IDs.
+type querySource interface {
+	ids(ns *NoteStore) (map[string]bool, error)
+}
+
+type tagSource struct{ tag string }
+
+func (s tagSource) ids(ns *NoteStore) (map[string]bool, error) {
+	ids, err := ns.TaggedNoteIDs(s.tag)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// folderSource matches the notes in a folder, at any depth, or the note
+// with the folder's path as its ID.
+type folderSource struct{ folder string }
+
+func (s folderSource) ids(ns *NoteStore) (map[string]bool, error) {
+	folder := NormalizeNoteID(s.folder)
+	set := map[string]bool{}
+	if folder == "" {
+		return allNoteIDs(ns)
+	}
+
+	ids, err := listIDs(ns.store, noteKeyPrefix+folder+"/")
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		set[folder+"/"+id] = true
+	}
+	for _, id := range []string{folder, folder + ".md"} {
+		if _, err := ns.store.Get(noteKey(id)); err == nil {
+			set[id] = true
+		}
+	}
+	return set, nil
+}
+
+func allNoteIDs(ns *NoteStore) (map[string]bool, error) {
+	ids, err := listIDs(ns.store, noteKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+type combinedSource struct {
+	and         bool
+	left, right querySource
+}
+
+func (s combinedSource) ids(ns *NoteStore) (map[string]bool, error) {
+	left, err := s.left.ids(ns)
+	if err != nil {
+		return nil, err
+	}
+	right, err := s.right.ids(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	set := map[string]bool{}
+	for id := range left {
+		if !s.and || right[id] {
+			set[id] = true
+		}
+	}
+	if !s.and {
+		for id := range right {
+			set[id] = true
+		}
+	}
+	return set, nil
+}
+
+type negatedSource struct{ source querySource }
+
+func (s negatedSource) ids(ns *NoteStore) (map[string]bool, error) {
+	excluded, err := s.source.ids(ns)
+	if err != nil {
+		return nil, err
+	}
+	set, err := allNoteIDs(ns)
+	if err != nil {
+		return nil, err
+	}
+	for id := range excluded {
+		delete(set, id)
+	}
+	return set, nil
+}
+
+// queryContext is what WHERE expressions are evaluated against.
+type queryContext struct {
+	note Note
+	now  time.Time
+}
+
+type queryExpr interface {
+	eval(ctx queryContext) interface{}
+}
+
+type literalExpr struct{ value interface{} }
+
+func (e literalExpr) eval(queryContext) interface{} { return e.value }
+
+type fieldExpr struct{ name string }
+
+func (e fieldExpr) eval(ctx queryContext) interface{} { return noteField(ctx.note, e.name) }
+
+type comparisonExpr struct {
+	op          string
+	left, right queryExpr
+}
+
+func (e comparisonExpr) eval(ctx queryContext) interface{} {
+	left, right := e.left.eval(ctx), e.right.eval(ctx)
+	switch e.op {
+	case "=":
+		return valuesEqual(left, right)
+	case "!=":
+		return !valuesEqual(left, right)
+	}
+
+	c, ok := compareValues(left, right)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+type logicalExpr struct {
+	and         bool
+	left, right queryExpr
+}
+
+func (e logicalExpr) eval(ctx queryContext) interface{} {
+	if e.and {
+		return truthy(e.left.eval(ctx)) && truthy(e.right.eval(ctx))
+	}
+	return truthy(e.left.eval(ctx)) || truthy(e.right.eval(ctx))
+}
+
+type notExpr struct{ expr queryExpr }
+
+func (e notExpr) eval(ctx queryContext) interface{} { return !truthy(e.expr.eval(ctx)) }
+
+type callExpr struct {
+	name string
+	args []queryExpr
+}
+
+// queryFunctions are the functions of WHERE expressions, with their
+// number of arguments.
+var queryFunctions = map[string]int{"contains": 2, "startswith": 2, "lower": 1, "date": 1}
+
+func (e callExpr) eval(ctx queryContext) interface{} {
+	switch e.name {
+	case "contains":
+		container, item := e.args[0].eval(ctx), e.args[1].eval(ctx)
+		if s, ok := container.(string); ok {
+			sub, ok := item.(string)
+			return ok && strings.Contains(s, sub)
+		}
+		return valuesEqual(container, item) && isList(container)
+	case "startswith":
+		s, ok1 := e.args[0].eval(ctx).(string)
+		prefix, ok2 := e.args[1].eval(ctx).(string)
+		return ok1 && ok2 && strings.HasPrefix(s, prefix)
+	case "lower":
+		if s, ok := e.args[0].eval(ctx).(string); ok {
+			return strings.ToLower(s)
+		}
+		return nil
+	case "date":
+		if field, ok := e.args[0].(fieldExpr); ok {
+			today := time.Date(ctx.now.Year(), ctx.now.Month(), ctx.now.Day(), 0, 0, 0, 0, ctx.now.Location())
+			switch field.name {
+			case "now":
+				return ctx.now
+			case "today":
+				return today
+			case "yesterday":
+				return today.AddDate(0, 0, -1)
+			case "tomorrow":
+				return today.AddDate(0, 0, 1)
+			}
+		}
+		if t, err := ParseTime(e.args[0].eval(ctx)); err == nil {
+			return t
+		}
+		return nil
+	}
+	return nil
+}
+
+func isList(value interface{}) bool {
+	switch value.(type) {
+	case []interface{}, []string:
+		return true
+	}
+	return false
+}
+
+func listItems(value interface{}) []interface{} {
+	switch list := value.(type) {
+	case []interface{}:
+		return list
+	case []string:
+		items := make([]interface{}, len(list))
+		for i, item := range list {
+			items[i] = item
+		}
+		return items
+	}
+	return nil
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case int:
+		return v != 0
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	case []interface{}:
+		return len(v) > 0
+	case []string:
+		return len(v) > 0
+	}
+	return true
+}
+
+// valuesEqual compares values as query comparisons do: a list equals a
+// value it holds.
+func valuesEqual(a, b interface{}) bool {
+	if isList(a) && !isList(b) {
+		for _, item := range listItems(a) {
+			if valuesEqual(item, b) {
+				return true
+			}
+		}
+		return false
+	}
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	c, ok := compareValues(a, b)
+	return ok && c == 0
+}
+
+// compareValues orders two values: numbers numerically, dates as
+// instants and other strings as text. ok is false for values that cannot
+// be compared.
+func compareValues(a, b interface{}) (int, bool) {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	if x, ok := a.(bool); ok {
+		y, ok := b.(bool)
+		if !ok || x == y {
+			return 0, ok
+		}
+		if !x {
+			return -1, true
+		}
+		return 1, true
+	}
+
+	_, aTime := a.(time.Time)
+	_, bTime := b.(time.Time)
+	_, aString := a.(string)
+	_, bString := b.(string)
+	if (aTime || aString) && (bTime || bString) {
+		if x, err := ParseTime(a); err == nil {
+			if y, err := ParseTime(b); err == nil {
+				return x.Compare(y), true
+			}
+		}
+	}
+	if aString && bString {
+		return strings.Compare(a.(string), b.(string)), true
+	}
+	return 0, false
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, !math.IsNaN(v)
+	}
+	return 0, false
+}
+
+// ParseQuery parses a query, see Query. Errors are *QueryError.
+func ParseQuery(text string) (*Query, error) {
+	tokens, err := lexQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	q := &Query{}
+
+	if p.keyword("TABLE") {
+		q.Table = true
+		for {
+			field, err := p.fieldName()
+			if err != nil {
+				return nil, err
+			}
+			column := QueryField{Name: field, Label: field}
+			if p.keyword("AS") {
+				label := p.next()
+				if label.kind != tokenString {
+					return nil, p.errorAt(label, "expected a quoted label after AS")
+				}
+				column.Label = label.text
+			}
+			q.Fields = append(q.Fields, column)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	} else {
+		p.keyword("LIST")
+	}
+
+	if p.keyword("FROM") {
+		if q.from, err = p.sourceOr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("WHERE") {
+		if q.where, err = p.exprOr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("SORT") {
+		for {
+			field, err := p.fieldName()
+			if err != nil {
+				return nil, err
+			}
+			key := querySort{field: field}
+			if p.keyword("DESC") {
+				key.descending = true
+			} else {
+				p.keyword("ASC")
+			}
+			q.sort = append(q.sort, key)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("LIMIT") {
+		limit := p.next()
+		n, err := strconv.Atoi(limit.text)
+		if limit.kind != tokenNumber || err != nil || n <= 0 {
+			return nil, p.errorAt(limit, "LIMIT must be a positive whole number")
+		}
+		q.Limit = n
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorAt(t, fmt.Sprintf("unexpected %q", t.text))
+	}
+	return q, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenTag
+	tokenSymbol
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+	// column is where the token starts, counted in characters from 1.
+	column int
+}
+
+func lexQuery(text string) ([]queryToken, error) {
+	var tokens []queryToken
+	column := func(offset int) int { return utf8.RuneCountInString(text[:offset]) + 1 }
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case r == '"':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(text) {
+					return nil, &QueryError{Column: column(start), Message: "unterminated string"}
+				}
+				if text[i] == '\\' && i+1 < len(text) {
+					b.WriteByte(text[i+1])
+					i += 2
+					continue
+				}
+				if text[i] == '"' {
+					i++
+					break
+				}
+				b.WriteByte(text[i])
+				i++
+			}
+			tokens = append(tokens, queryToken{kind: tokenString, text: b.String(), column: column(start)})
+
+		case r == '#':
+			i++
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_-/", r) {
+					break
+				}
+				i += size
+			}
+			if i == start+1 {
+				return nil, &QueryError{Column: column(start), Message: "expected a tag after #"}
+			}
+			tokens = append(tokens, queryToken{kind: tokenTag, text: text[start+1 : i], column: column(start)})
+
+		case unicode.IsDigit(r):
+			for i < len(text) && (text[i] >= '0' && text[i] <= '9' || text[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, queryToken{kind: tokenNumber, text: text[start:i], column: column(start)})
+
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' && r != '-' {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, queryToken{kind: tokenIdent, text: text[start:i], column: column(start)})
+
+		default:
+			symbol := string(r)
+			for _, two := range []string{"!=", "<=", ">=", "=="} {
+				if strings.HasPrefix(text[i:], two) {
+					symbol = two
+				}
+			}
+			if !strings.Contains("= != < <= > >= == ( ) , - !", symbol) {
+				return nil, &QueryError{Column: column(start), Message: fmt.Sprintf("unexpected %q", symbol)}
+			}
+			if symbol == "==" {
+				symbol = "="
+			}
+			i += len(symbol)
+			if symbol == "=" && strings.HasPrefix(text[start:], "==") {
+				i++
+			}
+			tokens = append(tokens, queryToken{kind: tokenSymbol, text: symbol, column: column(start)})
+		}
+	}
+	return append(tokens, queryToken{kind: tokenEOF, column: column(len(text))}), nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the keyword if it comes next.
+func (p *queryParser) keyword(word string) bool {
+	if t := p.peek(); t.kind == tokenIdent && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol consumes the symbol if it comes next.
+func (p *queryParser) symbol(symbol string) bool {
+	if t := p.peek(); t.kind == tokenSymbol && t.text == symbol {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) errorAt(t queryToken, message string) error {
+	if t.kind == tokenEOF {
+		message += " at the end of the query"
+	}
+	return &QueryError{Column: t.column, Message: message}
+}
+
+// clauseKeywords end a clause, so they cannot name fields.
+var clauseKeywords = map[string]bool{"FROM": true, "WHERE": true, "SORT": true, "LIMIT": true, "AS": true}
+
+func (p *queryParser) fieldName() (string, error) {
+	t := p.next()
+	if t.kind != tokenIdent || clauseKeywords[strings.ToUpper(t.text)] {
+		return "", p.errorAt(t, "expected a field name")
+	}
+	return t.text, nil
+}
+
+func (p *queryParser) sourceOr() (querySource, error) {
+	left, err := p.sourceAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.sourceAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = combinedSource{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) sourceAnd() (querySource, error) {
+	left, err := p.sourceTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.sourceTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = combinedSource{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) sourceTerm() (querySource, error) {
+	if p.symbol("-") || p.symbol("!") || p.keyword("NOT") {
+		source, err := p.sourceTerm()
+		if err != nil {
+			return nil, err
+		}
+		return negatedSource{source: source}, nil
+	}
+	if p.symbol("(") {
+		source, err := p.sourceOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, p.errorAt(p.peek(), "expected \")\"")
+		}
+		return source, nil
+	}
+
+	t := p.next()
+	switch t.kind {
+	case tokenTag:
+		return tagSource{tag: t.text}, nil
+	case tokenString:
+		return folderSource{folder: t.text}, nil
+	}
+	return nil, p.errorAt(t, "expected a #tag or a \"folder\"")
+}
+
+func (p *queryParser) exprOr() (queryExpr, error) {
+	left, err := p.exprAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.exprAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) exprAnd() (queryExpr, error) {
+	left, err := p.exprNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.exprNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) exprNot() (queryExpr, error) {
+	if p.symbol("!") || p.keyword("NOT") {
+		expr, err := p.exprNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr: expr}, nil
+	}
+	return p.exprComparison()
+}
+
+func (p *queryParser) exprComparison() (queryExpr, error) {
+	left, err := p.exprValue()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokenSymbol && slices.Contains([]string{"=", "!=", "<", "<=", ">", ">="}, t.text) {
+		p.pos++
+		right, err := p.exprValue()
+		if err != nil {
+			return nil, err
+		}
+		return comparisonExpr{op: t.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *queryParser) exprValue() (queryExpr, error) {
+	if p.symbol("(") {
+		expr, err := p.exprOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, p.errorAt(p.peek(), "expected \")\"")
+		}
+		return expr, nil
+	}
+
+	negative := p.symbol("-")
+	t := p.next()
+	switch {
+	case t.kind == tokenNumber:
+		text := t.text
+		if negative {
+			text = "-" + text
+		}
+		if n, err := strconv.Atoi(text); err == nil {
+			return literalExpr{value: n}, nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, p.errorAt(t, fmt.Sprintf("invalid number %q", t.text))
+		}
+		return literalExpr{value: f}, nil
+	case negative:
+		return nil, p.errorAt(t, "expected a number after \"-\"")
+	case t.kind == tokenString:
+		return literalExpr{value: t.text}, nil
+	case t.kind == tokenIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return literalExpr{value: true}, nil
+		case "false":
+			return literalExpr{value: false}, nil
+		case "null":
+			return literalExpr{value: nil}, nil
+		}
+		if clauseKeywords[strings.ToUpper(t.text)] {
+			break
+		}
+		if p.symbol("(") {
+			return p.call(t)
+		}
+		return fieldExpr{name: t.text}, nil
+	}
+	return nil, p.errorAt(t, "expected a field, a value or \"(\"")
+}
+
+func (p *queryParser) call(name queryToken) (queryExpr, error) {
+	function := strings.ToLower(name.text)
+	arity, ok := queryFunctions[function]
+	if !ok {
+		return nil, p.errorAt(name, fmt.Sprintf("unknown function %q", name.text))
+	}
+
+	var args []queryExpr
+	for !p.symbol(")") {
+		if len(args) > 0 && !p.symbol(",") {
+			return nil, p.errorAt(p.peek(), "expected \",\" or \")\"")
+		}
+		arg, err := p.exprOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) != arity {
+		return nil, p.errorAt(name, fmt.Sprintf("%s takes %d arguments", function, arity))
+	}
+	return callExpr{name: function, args: args}, nil
+}
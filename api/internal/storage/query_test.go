@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func queryNoteStore(t *testing.T) *NoteStore {
+	t.Helper()
+	noteStore := NewNoteStore(NewMemoryStore())
+	noteStore.now = func() time.Time { return time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC) }
+
+	for _, note := range []Note{
+		{ID: "work/launch", Content: "---\nstatus: done\npriority: 2\nupdated: 2024-05-01\n---\n#project/alpha"},
+		{ID: "work/roadmap", Content: "---\nstatus: open\npriority: 1\nupdated: 2024-05-08\ntags: [project]\n---\nPlans."},
+		{ID: "work/archive/old", Content: "---\nstatus: open\npriority: 3\n---\n#project"},
+		{ID: "home/garden", Content: "---\nstatus: open\nupdated: 2024-04-01\n---\n#hobby"},
+		{ID: "workshop", Content: "Not in the work folder."},
+	} {
+		if err := noteStore.SaveNote(note); err != nil {
+			t.Fatalf("Failed to save note %q: %v", note.ID, err)
+		}
+	}
+	return noteStore
+}
+
+func TestQuery(t *testing.T) {
+	noteStore := queryNoteStore(t)
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{``, "home/garden,work/archive/old,work/launch,work/roadmap,workshop"},
+		{`LIST FROM #project`, "work/archive/old,work/launch,work/roadmap"},
+		{`list from #project/alpha`, "work/launch"},
+		{`FROM "work"`, "work/archive/old,work/launch,work/roadmap"},
+		{`FROM "work" AND -"work/archive"`, "work/launch,work/roadmap"},
+		{`FROM #hobby OR (#project AND NOT "work/archive")`, "home/garden,work/launch,work/roadmap"},
+		{`FROM #project WHERE status = "open"`, "work/archive/old,work/roadmap"},
+		{`WHERE status != "done" AND priority >= 2`, "work/archive/old"},
+		{`WHERE !(status = "open")`, "work/launch,workshop"},
+		{`WHERE updated > date("2024-04-15")`, "work/launch,work/roadmap"},
+		{`WHERE updated >= date(today) OR priority = 1`, "work/roadmap"},
+		{`WHERE file.tags = "project"`, "work/archive/old,work/roadmap"},
+		{`WHERE contains(file.tags, "hobby") OR startswith(file.name, "shop")`, "home/garden"},
+		{`WHERE file.folder = "work" AND lower(status) = "done"`, "work/launch"},
+		{`WHERE priority`, "work/archive/old,work/launch,work/roadmap"},
+		{`FROM "work" SORT priority DESC`, "work/archive/old,work/launch,work/roadmap"},
+		{`SORT updated DESC LIMIT 3`, "work/roadmap,work/launch,home/garden"},
+		{`FROM "work" SORT status ASC, priority DESC`, "work/launch,work/archive/old,work/roadmap"},
+	}
+	for _, tc := range tests {
+		q, err := ParseQuery(tc.query)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", tc.query, err)
+			continue
+		}
+		results, err := noteStore.Query(q, nil)
+		if err != nil {
+			t.Errorf("Failed to run %q: %v", tc.query, err)
+			continue
+		}
+		var ids []string
+		for _, result := range results {
+			ids = append(ids, result.Note.ID)
+		}
+		if got := strings.Join(ids, ","); got != tc.expected {
+			t.Errorf("Expected %q to match %q, got %q", tc.query, tc.expected, got)
+		}
+	}
+}
+
+func TestQueryTable(t *testing.T) {
+	noteStore := queryNoteStore(t)
+
+	q, err := ParseQuery(`TABLE status AS "State", updated, file.name FROM #project SORT priority LIMIT 2`)
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if !q.Table || len(q.Fields) != 3 || q.Fields[0] != (QueryField{Name: "status", Label: "State"}) || q.Fields[1].Label != "updated" {
+		t.Errorf("Unexpected fields %+v", q.Fields)
+	}
+
+	results, err := noteStore.Query(q, func(note Note) bool { return note.ID != "work/roadmap" })
+	if err != nil {
+		t.Fatalf("Failed to run query: %v", err)
+	}
+	if len(results) != 2 || results[0].Note.ID != "work/launch" || results[1].Note.ID != "work/archive/old" {
+		t.Fatalf("Expected the limit to apply to the notes kept, got %+v", results)
+	}
+	if values := fmt.Sprint(results[0].Values); values != "[done 2024-05-01T00:00:00Z launch]" {
+		t.Errorf("Unexpected values %s", values)
+	}
+	if values := fmt.Sprint(results[1].Values); values != "[open <nil> old]" {
+		t.Errorf("Unexpected values %s", values)
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+	}{
+		{`TABLE FROM #a`, 7},
+		{`FROM #`, 6},
+		{`FROM status`, 6},
+		{`WHERE status = "open`, 16},
+		{`WHERE (status = "open"`, 23},
+		{`WHERE unknown(status)`, 7},
+		{`WHERE contains(file.tags)`, 7},
+		{`SORT updated LIMIT 0`, 20},
+		{`LIST WHERE a = 1 extra`, 18},
+		{`WHERE a & b`, 9},
+	}
+	for _, tc := range tests {
+		_, err := ParseQuery(tc.query)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) || !errors.Is(err, ErrInvalidQuery) || queryErr.Column != tc.column {
+			t.Errorf("Expected %q to fail at column %d, got %v", tc.query, tc.column, err)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// redirectKeyPrefix indexes redirects by the ID they redirect from.
+const redirectKeyPrefix = indexKeyPrefix + "redirect/"
+
+var ErrRedirectNotFound = fmt.Errorf("redirect %w", ErrNotFound)
+
+// Redirect sends readers of a note ID that no longer exists to the note
+// now holding it.
+type Redirect struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Alias is set for redirects from a note's "aliases" frontmatter
+	// field, which follow the note's metadata. Others are recorded when a
+	// note is moved and are kept.
+	Alias     bool      `json:"alias,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (ns *NoteStore) GetRedirect(from string) (Redirect, error) {
+	var redirect Redirect
+
+	data, err := ns.store.Get(redirectKeyPrefix + from)
+	if errors.Is(err, ErrNotFound) {
+		return redirect, ErrRedirectNotFound
+	} else if err != nil {
+		return redirect, err
+	}
+
+	err = json.Unmarshal(data, &redirect)
+	return redirect, err
+}
+
+func (ns *NoteStore) ListRedirects() ([]Redirect, error) {
+	ids, err := listIDs(ns.store, redirectKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	redirects := []Redirect{}
+	for _, id := range ids {
+		redirect, err := ns.GetRedirect(id)
+		if err != nil {
+			continue
+		}
+		redirects = append(redirects, redirect)
+	}
+	return redirects, nil
+}
+
+func (ns *NoteStore) setRedirect(redirect Redirect) error {
+	data, err := json.Marshal(redirect)
+	if err != nil {
+		return err
+	}
+	return ns.store.Set(redirectKeyPrefix+redirect.From, data)
+}
+
+// Aliases returns the note IDs listed in the note's "aliases" field, a
+// string or a list of strings. Aliases that are not valid note IDs, such
+// as ones containing "?" or ":", are left out.
+func (n Note) Aliases() []string {
+	var values []string
+	switch aliases := n.Metadata["aliases"].(type) {
+	case string:
+		values = []string{aliases}
+	case []string:
+		values = aliases
+	case []interface{}:
+		for _, alias := range aliases {
+			if s, ok := alias.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var ids []string
+	for _, value := range values {
+		id := NormalizeNoteID(value)
+		if id != n.ID && ValidateNoteID(id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// indexAliases points the aliases of note at it, and drops the aliases
+// previous had but note no longer has. An alias already taken by a moved
+// note or by another note's alias is left to it.
+func (ns *NoteStore) indexAliases(previous, note Note) error {
+	if err := ns.unindexAliases(previous, note.Aliases()...); err != nil {
+		return err
+	}
+	for _, alias := range note.Aliases() {
+		redirect, err := ns.GetRedirect(alias)
+		if err == nil && !(redirect.Alias && (redirect.To == note.ID || redirect.To == previous.ID)) {
+			continue
+		} else if err != nil && !errors.Is(err, ErrRedirectNotFound) {
+			return err
+		}
+		err = ns.setRedirect(Redirect{From: alias, To: note.ID, Alias: true, CreatedAt: ns.now().UTC()})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexAliases drops the alias redirects of note, except for keep.
+// Aliases claimed by another note since are left alone.
+func (ns *NoteStore) unindexAliases(note Note, keep ...string) error {
+	kept := make(map[string]bool, len(keep))
+	for _, alias := range keep {
+		kept[alias] = true
+	}
+
+	for _, alias := range note.Aliases() {
+		if kept[alias] {
+			continue
+		}
+		redirect, err := ns.GetRedirect(alias)
+		if errors.Is(err, ErrRedirectNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if redirect.Alias && redirect.To == note.ID {
+			if err := ns.store.Delete(redirectKeyPrefix + alias); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MoveNote renames the note from to the ID to, keeping its password,
+// schedule and slugs; a slug derived from the ID changes, the old one
+// redirecting to the new. Wikilinks to the note in every note are
+// rewritten to the new ID, and a redirect from the old ID is recorded;
+// earlier redirects to the old ID are pointed at the new one. It returns
+// the IDs of the notes whose links were rewritten.
+func (ns *NoteStore) MoveNote(from, to string) ([]string, error) {
+	if violations := ValidateNoteID(to); violations != nil {
+		return nil, &ValidationError{Violations: violations}
+	}
+
+	note, err := ns.GetNote(from)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ns.GetNote(to); err == nil {
+		return nil, ErrNoteExists
+	} else if !errors.Is(err, ErrNoteNotFound) {
+		return nil, err
+	}
+
+	notes, err := ns.ListNotes()
+	if err != nil {
+		return nil, err
+	}
+	links := newLinkMatcher(from, notes)
+
+	if err := ns.access.moveNotePassword(from, to); err != nil {
+		return nil, err
+	}
+	if err := ns.moveSlugs(from, to); err != nil {
+		return nil, err
+	}
+	previous := note
+	note.ID = to
+	if err := ns.assignSlug(&note, previous); err != nil {
+		return nil, err
+	}
+	if err := ns.put(note); err != nil {
+		return nil, err
+	}
+	if err := ns.indexAliases(previous, note); err != nil {
+		return nil, err
+	}
+	if err := ns.indexTags(Note{}, note); err != nil {
+		return nil, err
+	}
+	if err := ns.DeleteNote(from); err != nil {
+		return nil, err
+	}
+
+	updated := []string{}
+	for _, linking := range notes {
+		if linking.ID == from {
+			linking = note
+		}
+		content, changed := links.rewrite(linking.Content, to)
+		if !changed {
+			continue
+		}
+		linking.Content = content
+		if err := ns.put(linking); err != nil {
+			return updated, err
+		}
+		updated = append(updated, linking.ID)
+	}
+
+	redirects, err := ns.ListRedirects()
+	if err != nil {
+		return updated, err
+	}
+	for _, redirect := range redirects {
+		if redirect.To != from || redirect.Alias {
+			continue
+		}
+		redirect.To = to
+		if err := ns.setRedirect(redirect); err != nil {
+			return updated, err
+		}
+	}
+	if err := ns.store.Delete(redirectKeyPrefix + to); err != nil {
+		return updated, err
+	}
+	return updated, ns.setRedirect(Redirect{From: from, To: to, CreatedAt: ns.now().UTC()})
+}
+
+// Backlinks returns the IDs of the notes whose wikilinks point at the note
+// id.
+func (ns *NoteStore) Backlinks(id string) ([]string, error) {
+	notes, err := ns.ListNotes()
+	if err != nil {
+		return nil, err
+	}
+
+	links := newLinkMatcher(id, notes)
+	ids := []string{}
+	for _, note := range notes {
+		if _, found := links.rewrite(note.Content, id); found {
+			ids = append(ids, note.ID)
+		}
+	}
+	return ids, nil
+}
+
+// indexAllAliases builds the alias index for notes published before it
+// existed.
+func indexAllAliases(store Store) error {
+	ns := NewNoteStore(store)
+	notes, err := ns.ListNotes()
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if err := ns.indexAliases(Note{}, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
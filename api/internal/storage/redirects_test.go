@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMoveNote(t *testing.T) {
+	store := NewMemoryStore()
+	noteStore := NewNoteStore(store)
+	accessStore := NewAccessStore(store)
+
+	for _, note := range []Note{
+		{ID: "docs/setup", Content: "---\npassword: hunter2\nvisibility: protected\n---\nSee [[docs/setup#Install]]."},
+		{ID: "index", Content: "Start with [[setup|the setup]], ![[docs/setup.md]] and [[docs/other]]."},
+		{ID: "table", Content: "| [[docs/setup\\|Setup]] |"},
+		{ID: "docs/other", Content: "unrelated"},
+	} {
+		if err := noteStore.SaveNote(note); err != nil {
+			t.Fatalf("Failed to save note %q: %v", note.ID, err)
+		}
+	}
+
+	if backlinks, err := noteStore.Backlinks("docs/setup"); err != nil || len(backlinks) != 3 {
+		t.Errorf("Expected three notes linking to docs/setup, got %v, %v", backlinks, err)
+	}
+
+	updated, err := noteStore.MoveNote("docs/setup", "guides/install")
+	if err != nil {
+		t.Fatalf("Failed to move note: %v", err)
+	}
+	if strings.Join(updated, ",") != "guides/install,index,table" {
+		t.Errorf("Expected the links of three notes to be rewritten, got %v", updated)
+	}
+
+	if _, err := noteStore.GetNote("docs/setup"); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected the old ID to be gone, got %v", err)
+	}
+	expected := map[string]string{
+		"guides/install": "See [[guides/install#Install]].",
+		"index":          "Start with [[guides/install|the setup]], ![[guides/install]] and [[docs/other]].",
+		"table":          "| [[guides/install\\|Setup]] |",
+	}
+	for id, content := range expected {
+		if note, err := noteStore.GetNote(id); err != nil || note.Content != content {
+			t.Errorf("Expected %q to hold %q, got %q, %v", id, content, note.Content, err)
+		}
+	}
+	if _, err := accessStore.CheckPassword("guides/install", "hunter2"); err != nil {
+		t.Errorf("Expected the moved note to keep its password, got %v", err)
+	}
+
+	if redirect, err := noteStore.GetRedirect("docs/setup"); err != nil || redirect.To != "guides/install" || redirect.Alias {
+		t.Errorf("Expected a redirect from the old ID, got %+v, %v", redirect, err)
+	}
+
+	if _, err := noteStore.MoveNote("guides/install", "guides/final"); err != nil {
+		t.Fatalf("Failed to move note again: %v", err)
+	}
+	if redirect, _ := noteStore.GetRedirect("docs/setup"); redirect.To != "guides/final" {
+		t.Errorf("Expected earlier redirects to follow the note, got %+v", redirect)
+	}
+
+	if _, err := noteStore.MoveNote("guides/final", "docs/other"); !errors.Is(err, ErrNoteExists) {
+		t.Errorf("Expected moving onto a note to fail, got %v", err)
+	}
+	if _, err := noteStore.MoveNote("guides/final", "../escape"); !errors.Is(err, ErrInvalidNote) {
+		t.Errorf("Expected moving to an invalid ID to fail, got %v", err)
+	}
+	if _, err := noteStore.MoveNote("missing", "elsewhere"); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected moving a missing note to fail, got %v", err)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	noteStore := NewNoteStore(NewMemoryStore())
+
+	note := Note{ID: "guides/install", Content: "---\naliases: [setup, old/install, \"what?\"]\n---\nbody"}
+	if err := noteStore.SaveNote(note); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+	for _, alias := range []string{"setup", "old/install"} {
+		if redirect, err := noteStore.GetRedirect(alias); err != nil || redirect.To != "guides/install" || !redirect.Alias {
+			t.Errorf("Expected alias %q to redirect to the note, got %+v, %v", alias, redirect, err)
+		}
+	}
+	if redirects, _ := noteStore.ListRedirects(); len(redirects) != 2 {
+		t.Errorf("Expected aliases that are no IDs to be left out, got %+v", redirects)
+	}
+
+	note.Content = "---\naliases: setup\n---\nbody"
+	if err := noteStore.SaveNote(note); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+	if _, err := noteStore.GetRedirect("old/install"); !errors.Is(err, ErrRedirectNotFound) {
+		t.Errorf("Expected a dropped alias to be removed, got %v", err)
+	}
+
+	if err := noteStore.TrashNote("guides/install", "ci"); err != nil {
+		t.Fatalf("Failed to trash note: %v", err)
+	}
+	if _, err := noteStore.GetRedirect("setup"); !errors.Is(err, ErrRedirectNotFound) {
+		t.Errorf("Expected the aliases of a trashed note to be removed, got %v", err)
+	}
+	if err := noteStore.RestoreNote("guides/install"); err != nil {
+		t.Fatalf("Failed to restore note: %v", err)
+	}
+	if _, err := noteStore.GetRedirect("setup"); err != nil {
+		t.Errorf("Expected a restored note to get its aliases back, got %v", err)
+	}
+
+	err := noteStore.SaveNote(Note{ID: "bad", Content: "---\naliases: {a: b}\n---\n"})
+	if !errors.Is(err, ErrInvalidNote) {
+		t.Errorf("Expected aliases that are no strings to be rejected, got %v", err)
+	}
+}
+
+func TestAliasesKeepExistingRedirects(t *testing.T) {
+	noteStore := NewNoteStore(NewMemoryStore())
+
+	save := func(id, content string) {
+		t.Helper()
+		if err := noteStore.SaveNote(Note{ID: id, Content: content}); err != nil {
+			t.Fatalf("Failed to save note %q: %v", id, err)
+		}
+	}
+	save("eng/old", "body")
+	if _, err := noteStore.MoveNote("eng/old", "eng/new"); err != nil {
+		t.Fatalf("Failed to move note: %v", err)
+	}
+
+	save("design/x", "---\naliases: [eng/old, shared]\n---\nbody")
+	save("design/y", "---\naliases: [shared]\n---\nbody")
+	if redirect, _ := noteStore.GetRedirect("eng/old"); redirect.To != "eng/new" || redirect.Alias {
+		t.Errorf("Expected an alias not to take over the redirect of a move, got %+v", redirect)
+	}
+	if redirect, _ := noteStore.GetRedirect("shared"); redirect.To != "design/x" {
+		t.Errorf("Expected an alias to stay with the note that claimed it first, got %+v", redirect)
+	}
+
+	save("design/x", "body")
+	if redirect, err := noteStore.GetRedirect("eng/old"); err != nil || redirect.To != "eng/new" {
+		t.Errorf("Expected dropping the alias to keep the redirect of the move, got %+v, %v", redirect, err)
+	}
+	if _, err := noteStore.GetRedirect("shared"); !errors.Is(err, ErrRedirectNotFound) {
+		t.Errorf("Expected the dropped alias to be removed, got %v", err)
+	}
+
+	save("design/y", "---\naliases: [shared]\n---\nbody")
+	if _, err := noteStore.MoveNote("design/y", "design/z"); err != nil {
+		t.Fatalf("Failed to move note: %v", err)
+	}
+	if redirect, _ := noteStore.GetRedirect("shared"); redirect.To != "design/z" || !redirect.Alias {
+		t.Errorf("Expected the aliases of a moved note to follow it, got %+v", redirect)
+	}
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const scheduleKeyPrefix = systemKeyPrefix + "schedule/"
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseTime reads a metadata value holding a timestamp, either a decoded
+// YAML timestamp or a string. Times without a zone are taken as UTC.
+func ParseTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %v as a time", value)
+}
+
+// PublishAt returns the "publish_at" instant of the note, if it has one.
+func (n Note) PublishAt() (time.Time, bool) {
+	return n.metadataTime("publish_at")
+}
+
+// ExpireAt returns the "expire_at" instant of the note, if it has one.
+func (n Note) ExpireAt() (time.Time, bool) {
+	return n.metadataTime("expire_at")
+}
+
+// Pending reports whether the note is scheduled to go live after now.
+func (n Note) Pending(now time.Time) bool {
+	publishAt, ok := n.PublishAt()
+	return ok && now.Before(publishAt)
+}
+
+// Expired reports whether the note's expiry has passed at now.
+func (n Note) Expired(now time.Time) bool {
+	expireAt, ok := n.ExpireAt()
+	return ok && !now.Before(expireAt)
+}
+
+func (n Note) metadataTime(key string) (time.Time, bool) {
+	value, exists := n.Metadata[key]
+	if !exists || value == nil {
+		return time.Time{}, false
+	}
+	t, err := ParseTime(value)
+	return t, err == nil
+}
+
+// ValidateSchedule checks that publish_at and expire_at are parseable and
+// in order.
+func ValidateSchedule(note Note) error {
+	for _, key := range []string{"publish_at", "expire_at"} {
+		if value, exists := note.Metadata[key]; exists && value != nil {
+			if _, err := ParseTime(value); err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrInvalidSchedule, key, err)
+			}
+		}
+	}
+
+	publishAt, hasPublish := note.PublishAt()
+	expireAt, hasExpire := note.ExpireAt()
+	if hasPublish && hasExpire && !publishAt.Before(expireAt) {
+		return fmt.Errorf("%w: expire_at must be after publish_at", ErrInvalidSchedule)
+	}
+	return nil
+}
+
+// ScheduleEntry records the pending publish and expiry instants of a note.
+// A nil instant has already been handled or was never set.
+type ScheduleEntry struct {
+	NoteID    string     `json:"note_id"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	ExpireAt  *time.Time `json:"expire_at,omitempty"`
+}
+
+// ScheduleStore persists schedule entries so pending events survive
+// restarts.
+type ScheduleStore struct {
+	store Store
+}
+
+func NewScheduleStore(store Store) *ScheduleStore {
+	return &ScheduleStore{store: store}
+}
+
+// ScheduleNote replaces the entry of a note with its future publish and
+// expiry instants, removing it when there are none.
+func (ss *ScheduleStore) ScheduleNote(note Note, now time.Time) error {
+	entry := ScheduleEntry{NoteID: note.ID}
+	if publishAt, ok := note.PublishAt(); ok && now.Before(publishAt) {
+		entry.PublishAt = &publishAt
+	}
+	if expireAt, ok := note.ExpireAt(); ok && now.Before(expireAt) {
+		entry.ExpireAt = &expireAt
+	}
+	return ss.SaveEntry(entry)
+}
+
+// SaveEntry stores the entry, or removes it once nothing is pending.
+func (ss *ScheduleStore) SaveEntry(entry ScheduleEntry) error {
+	if entry.PublishAt == nil && entry.ExpireAt == nil {
+		return ss.store.Delete(scheduleKeyPrefix + entry.NoteID)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return ss.store.Set(scheduleKeyPrefix+entry.NoteID, data)
+}
+
+func (ss *ScheduleStore) DeleteEntry(noteID string) error {
+	return ss.store.Delete(scheduleKeyPrefix + noteID)
+}
+
+func (ss *ScheduleStore) ListEntries() ([]ScheduleEntry, error) {
+	keys, err := ss.store.ListKeys(scheduleKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []ScheduleEntry{}
+	for _, key := range keys {
+		data, err := ss.store.Get(key)
+		if err != nil {
+			continue
+		}
+		var entry ScheduleEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	for _, value := range []interface{}{want, "2026-03-01T09:30:00Z", "2026-03-01T09:30", "2026-03-01 09:30"} {
+		got, err := ParseTime(value)
+		if err != nil {
+			t.Errorf("ParseTime(%v) failed: %v", value, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("ParseTime(%v) = %v, want %v", value, got, want)
+		}
+	}
+
+	if _, err := ParseTime("next tuesday"); err == nil {
+		t.Errorf("Expected an unparseable time to fail")
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	tests := []struct {
+		metadata map[string]interface{}
+		valid    bool
+	}{
+		{map[string]interface{}{}, true},
+		{map[string]interface{}{"publish_at": "2026-03-01"}, true},
+		{map[string]interface{}{"publish_at": "2026-03-01", "expire_at": "2026-04-01"}, true},
+		{map[string]interface{}{"publish_at": "2026-04-01", "expire_at": "2026-03-01"}, false},
+		{map[string]interface{}{"expire_at": "soon"}, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateSchedule(Note{ID: "n", Metadata: tt.metadata})
+		if tt.valid && err != nil {
+			t.Errorf("Expected %v to be valid, got %v", tt.metadata, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Expected %v to be rejected, got %v", tt.metadata, err)
+		}
+	}
+}
+
+func TestNoteStoreSchedule(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "schedule-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	noteStore := NewNoteStore(store)
+	scheduleStore := NewScheduleStore(store)
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if err := noteStore.SaveNote(Note{ID: "launch", Content: "---\npublish_at: " + future + "\n---\nsoon"}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+	if err := noteStore.SaveNote(Note{ID: "plain", Content: "now"}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+
+	entries, err := scheduleStore.ListEntries()
+	if err != nil {
+		t.Fatalf("Failed to list schedule entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].NoteID != "launch" || entries[0].PublishAt == nil {
+		t.Fatalf("Expected one pending publish entry, got %+v", entries)
+	}
+
+	notes, err := noteStore.ListNotes()
+	if err != nil {
+		t.Fatalf("Failed to list notes: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Errorf("Expected schedule entries to stay out of the note listing, got %d notes", len(notes))
+	}
+
+	if err := noteStore.DeleteNote("launch"); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	if entries, _ := scheduleStore.ListEntries(); len(entries) != 0 {
+		t.Errorf("Expected deleting the note to drop its entry, got %+v", entries)
+	}
+
+	if err := noteStore.SaveNote(Note{ID: "bad", Content: "---\nexpire_at: whenever\n---\n"}); !errors.Is(err, ErrInvalidSchedule) {
+		t.Errorf("Expected invalid schedule to be rejected, got %v", err)
+	}
+}
+
+func TestNoteStoreHardExpiry(t *testing.T) {
+	store := NewMemoryStore()
+	noteStore := NewNoteStore(store)
+	noteStore.SetHardExpiry(true)
+
+	now := time.Now()
+	past := now.Add(-time.Minute).UTC().Format(time.RFC3339)
+	err := noteStore.SaveNote(Note{ID: "gone", Content: "---\nexpire_at: " + past + "\npassword: secret\naliases: [old]\n---\nold #tag"})
+	var validation *ValidationError
+	if !errors.As(err, &validation) || validation.Violations[0].Field != "metadata.expire_at" {
+		t.Fatalf("Expected an already expired note to be rejected under hard expiry, got %v", err)
+	}
+	if _, err := noteStore.GetNote("gone"); err == nil {
+		t.Errorf("Expected the expired note not to be stored")
+	}
+	for _, prefix := range []string{indexKeyPrefix, notePasswordPrefix, scheduleKeyPrefix} {
+		if keys, _ := store.ListKeys(prefix); len(keys) != 0 {
+			t.Errorf("Expected nothing left for the rejected note, got %v", keys)
+		}
+	}
+
+	soon := now.Add(time.Hour).UTC().Format(time.RFC3339)
+	if err := noteStore.SaveNote(Note{ID: "brief", Content: "---\nexpire_at: " + soon + "\npassword: secret\naliases: [old]\nslug: short\n---\n- [ ] Read it #tag"}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+	if err := noteStore.SaveNote(Note{ID: "kept", Content: "kept"}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+	if deleted, err := noteStore.DeleteExpiredNotes(); err != nil || deleted != 0 {
+		t.Errorf("Expected no note to have expired yet, got %d, %v", deleted, err)
+	}
+
+	noteStore.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if deleted, err := noteStore.DeleteExpiredNotes(); err != nil || deleted != 1 {
+		t.Fatalf("Expected the expired note to be deleted, got %d, %v", deleted, err)
+	}
+	if _, err := noteStore.GetNote("brief"); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected the expired note to be gone, got %v", err)
+	}
+	if _, err := noteStore.GetNote("kept"); err != nil {
+		t.Errorf("Expected the other note to be kept, got %v", err)
+	}
+	for _, prefix := range []string{indexKeyPrefix, notePasswordPrefix, scheduleKeyPrefix} {
+		keys, _ := store.ListKeys(prefix)
+		for _, key := range keys {
+			if strings.Contains(key, "brief") || strings.Contains(key, "old") || strings.Contains(key, "short") {
+				t.Errorf("Expected the expired note's %q to be deleted with it", key)
+			}
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldType is the type of a metadata field.
+type FieldType string
+
+const (
+	FieldString  FieldType = "string"
+	FieldNumber  FieldType = "number"
+	FieldInteger FieldType = "integer"
+	FieldBoolean FieldType = "boolean"
+	// FieldDate values are stored as RFC 3339 strings.
+	FieldDate FieldType = "date"
+	// FieldList values are lists of strings.
+	FieldList FieldType = "list"
+)
+
+// FieldSchema declares a metadata field.
+type FieldSchema struct {
+	Type FieldType `yaml:"type"`
+	// Default is given to notes without the field.
+	Default  interface{} `yaml:"default"`
+	Required bool        `yaml:"required"`
+	// Values restricts a string field, or the items of a list, to the
+	// values listed.
+	Values []string `yaml:"values"`
+}
+
+// builtinFields are the metadata fields the server reads itself. Their
+// types cannot be changed, and ValidateNote checks them.
+var builtinFields = func() map[string]FieldType {
+	fields := map[string]FieldType{
+		"title": FieldString, "description": FieldString, "slug": FieldString,
+		"visibility": FieldString, "password": FieldString,
+		"tags": FieldList, "aliases": FieldList,
+	}
+	for _, key := range noteDateFields {
+		fields[key] = FieldDate
+	}
+	return fields
+}()
+
+// MetadataSchema declares the metadata fields notes have: the built-in
+// ones and those of a schema file. Metadata is coerced to the declared
+// types when a note's frontmatter is extracted, so that "tags: draft"
+// becomes a list and "date: 2024-01-05" an RFC 3339 timestamp, and
+// validated when the note is saved. Fields the schema does not declare
+// are kept as they are.
+type MetadataSchema struct {
+	fields map[string]FieldSchema
+}
+
+// DefaultMetadataSchema declares the built-in fields only.
+var DefaultMetadataSchema = func() *MetadataSchema {
+	schema, err := NewMetadataSchema(nil)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}()
+
+// NewMetadataSchema returns a schema declaring fields along with the
+// built-in ones. Built-in fields may be given a default, be required or
+// have their values restricted, but keep their type.
+func NewMetadataSchema(fields map[string]FieldSchema) (*MetadataSchema, error) {
+	schema := &MetadataSchema{fields: make(map[string]FieldSchema, len(builtinFields)+len(fields))}
+	for key, fieldType := range builtinFields {
+		schema.fields[key] = FieldSchema{Type: fieldType}
+	}
+
+	for key, field := range fields {
+		if builtin, ok := builtinFields[key]; ok {
+			if field.Type != "" && field.Type != builtin {
+				return nil, fmt.Errorf("field %q is built in and must be a %s", key, builtin)
+			}
+			field.Type = builtin
+		}
+		switch field.Type {
+		case FieldString, FieldNumber, FieldInteger, FieldBoolean, FieldDate, FieldList:
+		case "":
+			return nil, fmt.Errorf("field %q needs a type", key)
+		default:
+			return nil, fmt.Errorf("field %q has unknown type %q", key, field.Type)
+		}
+
+		if field.Default != nil {
+			value, ok := coerce(field.Type, field.Default)
+			if !ok {
+				return nil, fmt.Errorf("default of field %q must be a %s", key, field.Type)
+			}
+			if message := field.checkValues(value); message != "" {
+				return nil, fmt.Errorf("default of field %q %s", key, message)
+			}
+			field.Default = value
+		}
+		schema.fields[key] = field
+	}
+	return schema, nil
+}
+
+// LoadMetadataSchema reads a schema file: YAML with the fields under
+// "fields", such as
+//
+//	fields:
+//	  status:
+//	    type: string
+//	    values: [draft, review, done]
+//	    default: draft
+//	  tags:
+//	    default: []
+func LoadMetadataSchema(path string) (*MetadataSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Fields map[string]FieldSchema `yaml:"fields"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("metadata schema %s: %w", path, err)
+	}
+
+	schema, err := NewMetadataSchema(file.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("metadata schema %s: %w", path, err)
+	}
+	return schema, nil
+}
+
+// ExtractFrontmatter moves the frontmatter of a note's content into its
+// metadata, see the package-level ExtractFrontmatter, and coerces the
+// metadata to the schema.
+func (s *MetadataSchema) ExtractFrontmatter(note *Note) error {
+	frontmatter, content, err := ParseFrontmatter(note.Content)
+	if err != nil {
+		return err
+	}
+	if frontmatter != nil {
+		if note.Metadata == nil {
+			note.Metadata = make(map[string]interface{})
+		}
+
+		for key, value := range frontmatter {
+			note.Metadata[key] = value
+		}
+		note.Content = content
+	}
+
+	s.Coerce(note)
+	return nil
+}
+
+// Coerce converts the metadata fields of a note that the schema declares
+// to their types where it can, and gives the note the defaults of the
+// fields it lacks. Values that cannot be converted are left for
+// validation to report.
+func (s *MetadataSchema) Coerce(note *Note) {
+	for key, field := range s.fields {
+		value, exists := note.Metadata[key]
+		if !exists || value == nil {
+			if field.Default == nil {
+				continue
+			}
+			if note.Metadata == nil {
+				note.Metadata = make(map[string]interface{})
+			}
+			value = field.Default
+			if list, ok := value.([]interface{}); ok {
+				value = slices.Clone(list)
+			}
+			note.Metadata[key] = value
+			continue
+		}
+
+		if coerced, ok := coerce(field.Type, value); ok {
+			note.Metadata[key] = coerced
+		}
+	}
+}
+
+// ValidateNote checks a note's ID and built-in fields, see validateNote,
+// and that the fields of the schema file have their type and allowed
+// values and that required fields are set. It returns a *ValidationError
+// listing every violation, or nil.
+func (s *MetadataSchema) ValidateNote(note Note) error {
+	violations := validateNote(note)
+
+	keys := make([]string, 0, len(s.fields))
+	for key := range s.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := s.fields[key]
+		value, exists := note.Metadata[key]
+		if !exists || value == nil {
+			if field.Required {
+				violations = append(violations, Violation{Field: "metadata." + key, Message: "is required"})
+			}
+			continue
+		}
+
+		if _, builtin := builtinFields[key]; !builtin && !field.hasType(value) {
+			violations = append(violations, Violation{Field: "metadata." + key, Message: "must be a " + field.typeName()})
+			continue
+		}
+		if message := field.checkValues(value); message != "" {
+			violations = append(violations, Violation{Field: "metadata." + key, Message: message})
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: violations}
+}
+
+func (f FieldSchema) typeName() string {
+	switch f.Type {
+	case FieldList:
+		return "list of strings"
+	case FieldDate:
+		return "date"
+	}
+	return string(f.Type)
+}
+
+// hasType reports whether value, coerced, has the type of the field.
+func (f FieldSchema) hasType(value interface{}) bool {
+	switch f.Type {
+	case FieldString:
+		_, ok := value.(string)
+		return ok
+	case FieldNumber:
+		switch value.(type) {
+		case int, int64, float64:
+			return true
+		}
+		return false
+	case FieldInteger:
+		_, ok := value.(int)
+		return ok
+	case FieldBoolean:
+		_, ok := value.(bool)
+		return ok
+	case FieldDate:
+		_, err := ParseTime(value)
+		return err == nil
+	case FieldList:
+		return isStringList(value)
+	}
+	return true
+}
+
+// checkValues returns what is wrong with value if the field restricts
+// its values, "" otherwise.
+func (f FieldSchema) checkValues(value interface{}) string {
+	if len(f.Values) == 0 {
+		return ""
+	}
+
+	items := []interface{}{value}
+	if list, ok := value.([]interface{}); ok {
+		items = list
+	}
+	for _, item := range items {
+		if s, ok := item.(string); !ok || !slices.Contains(f.Values, s) {
+			return fmt.Sprintf("must be one of %s, not %v", strings.Join(f.Values, ", "), item)
+		}
+	}
+	return ""
+}
+
+// coerce converts value to the type, reporting whether it could.
+func coerce(fieldType FieldType, value interface{}) (interface{}, bool) {
+	switch fieldType {
+	case FieldString:
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case int, int64, float64, bool:
+			return fmt.Sprint(v), true
+		case time.Time:
+			return v.Format(time.RFC3339), true
+		}
+
+	case FieldNumber:
+		switch v := value.(type) {
+		case int, int64, float64:
+			return v, true
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return n, true
+			}
+			// ParseFloat reads "inf" and "nan" too, which metadata
+			// cannot hold.
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && isFiniteValue(f) {
+				return f, true
+			}
+		}
+
+	case FieldInteger:
+		switch v := value.(type) {
+		case int:
+			return v, true
+		case int64:
+			if v >= math.MinInt && v <= math.MaxInt {
+				return int(v), true
+			}
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				return int(v), true
+			}
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return n, true
+			}
+		}
+
+	case FieldBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case int:
+			if v == 0 || v == 1 {
+				return v == 1, true
+			}
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "yes", "on", "1":
+				return true, true
+			case "false", "no", "off", "0":
+				return false, true
+			}
+		}
+
+	case FieldDate:
+		if t, err := ParseTime(value); err == nil {
+			return t.Format(time.RFC3339), true
+		}
+
+	case FieldList:
+		switch v := value.(type) {
+		case string:
+			// A single value, or comma-separated values.
+			list := []interface{}{}
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			return list, true
+		case []string:
+			list := make([]interface{}, len(v))
+			for i, item := range v {
+				list[i] = item
+			}
+			return list, true
+		case []interface{}:
+			return v, isStringList(v)
+		}
+	}
+	return value, false
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetadataSchemaCoerce(t *testing.T) {
+	schema, err := NewMetadataSchema(map[string]FieldSchema{
+		"featured": {Type: FieldBoolean},
+		"rating":   {Type: FieldNumber},
+		"order":    {Type: FieldInteger},
+		"status":   {Type: FieldString, Default: "draft"},
+		"tags":     {Default: []interface{}{}},
+	})
+	if err != nil {
+		t.Fatalf("NewMetadataSchema() error = %v", err)
+	}
+
+	note := Note{Metadata: map[string]interface{}{
+		"title":    2024,
+		"aliases":  "old, older",
+		"date":     "2024-01-05",
+		"updated":  time.Date(2024, 2, 1, 10, 0, 0, 0, time.FixedZone("", 3600)),
+		"featured": "yes",
+		"rating":   "4.5",
+		"order":    3.0,
+		"extra":    "kept",
+		"created":  "yesterday",
+	}}
+	schema.Coerce(&note)
+
+	want := map[string]interface{}{
+		"title":    "2024",
+		"aliases":  []interface{}{"old", "older"},
+		"date":     "2024-01-05T00:00:00Z",
+		"updated":  "2024-02-01T10:00:00+01:00",
+		"featured": true,
+		"rating":   4.5,
+		"order":    3,
+		"extra":    "kept",
+		"created":  "yesterday",
+		"status":   "draft",
+		"tags":     []interface{}{},
+	}
+	if !reflect.DeepEqual(note.Metadata, want) {
+		t.Errorf("Coerce() = %#v, want %#v", note.Metadata, want)
+	}
+}
+
+func TestMetadataSchemaRejectsNonFiniteNumbers(t *testing.T) {
+	schema, err := NewMetadataSchema(map[string]FieldSchema{"rating": {Type: FieldNumber}})
+	if err != nil {
+		t.Fatalf("NewMetadataSchema() error = %v", err)
+	}
+
+	for _, content := range []string{"---\nrating: inf\n---\n", "---\nrating: NaN\n---\n"} {
+		note := Note{ID: "rated", Content: content}
+		if err := schema.ExtractFrontmatter(&note); err != nil {
+			t.Fatalf("ExtractFrontmatter() error = %v", err)
+		}
+		var validation *ValidationError
+		if err := schema.ValidateNote(note); !errors.As(err, &validation) || len(validation.Violations) != 1 || validation.Violations[0].Message != "must be a number" {
+			t.Errorf("Expected %q to be rejected as not a number, got %v", content, err)
+		}
+	}
+}
+
+func TestExtractFrontmatterCoercesTags(t *testing.T) {
+	note := Note{Content: "---\ntags: solo\ndate: 2024-01-05\n---\nBody"}
+	if err := ExtractFrontmatter(&note); err != nil {
+		t.Fatalf("ExtractFrontmatter() error = %v", err)
+	}
+	if tags := note.Metadata["tags"]; !reflect.DeepEqual(tags, []interface{}{"solo"}) {
+		t.Errorf("Expected a single tag to become a list, got %#v", tags)
+	}
+	if date := note.Metadata["date"]; date != "2024-01-05T00:00:00Z" {
+		t.Errorf("Expected the date in RFC 3339, got %#v", date)
+	}
+}
+
+func TestMetadataSchemaValidateNote(t *testing.T) {
+	schema, err := NewMetadataSchema(map[string]FieldSchema{
+		"status":   {Type: FieldString, Values: []string{"draft", "done"}},
+		"owner":    {Type: FieldString, Required: true},
+		"featured": {Type: FieldBoolean},
+		"tags":     {Values: []string{"go", "web"}},
+	})
+	if err != nil {
+		t.Fatalf("NewMetadataSchema() error = %v", err)
+	}
+
+	valid := Note{ID: "ok", Metadata: map[string]interface{}{"owner": "ana", "status": "done", "tags": []interface{}{"go"}}}
+	if err := schema.ValidateNote(valid); err != nil {
+		t.Errorf("Expected a valid note, got %v", err)
+	}
+
+	note := Note{ID: "bad", Metadata: map[string]interface{}{
+		"status":   "later",
+		"featured": "maybe",
+		"tags":     []interface{}{"go", "rust"},
+		"title":    []interface{}{"not", "a", "string"},
+	}}
+	err = schema.ValidateNote(note)
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	got := map[string]string{}
+	for _, v := range validation.Violations {
+		got[v.Field] = v.Message
+	}
+	want := map[string]string{
+		"metadata.title":    "must be a string",
+		"metadata.featured": "must be a boolean",
+		"metadata.owner":    "is required",
+		"metadata.status":   "must be one of draft, done, not later",
+		"metadata.tags":     "must be one of go, web, not rust",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Violations = %v, want %v", got, want)
+	}
+}
+
+func TestNewMetadataSchemaErrors(t *testing.T) {
+	tests := map[string]map[string]FieldSchema{
+		"built-in type":    {"tags": {Type: FieldString}},
+		"missing type":     {"status": {}},
+		"unknown type":     {"status": {Type: "color"}},
+		"bad default":      {"featured": {Type: FieldBoolean, Default: "maybe"}},
+		"disallowed value": {"status": {Type: FieldString, Values: []string{"a"}, Default: "b"}},
+	}
+	for name, fields := range tests {
+		if _, err := NewMetadataSchema(fields); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadMetadataSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.yaml")
+	data := "fields:\n  status:\n    type: string\n    values: [draft, done]\n    default: draft\n  tags:\n    default: []\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := LoadMetadataSchema(path)
+	if err != nil {
+		t.Fatalf("LoadMetadataSchema() error = %v", err)
+	}
+
+	ns := NewNoteStore(NewMemoryStore())
+	ns.SetMetadataSchema(schema)
+	if err := ns.SaveNote(Note{ID: "post", Content: "---\ntitle: Post\n---\nBody"}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+	note, _ := ns.GetNote("post")
+	if note.Metadata["status"] != "draft" || !reflect.DeepEqual(note.Metadata["tags"], []interface{}{}) {
+		t.Errorf("Expected the defaults to be set, got %v", note.Metadata)
+	}
+	if err := ns.SaveNote(Note{ID: "post", Content: "---\nstatus: gone\n---\nBody"}); !errors.Is(err, ErrInvalidNote) {
+		t.Errorf("Expected a disallowed status to be rejected, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("fields:\n  status:\n    kind: string\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadMetadataSchema(path); err == nil || !strings.Contains(err.Error(), "kind") {
+		t.Errorf("Expected unknown schema keys to be rejected, got %v", err)
+	}
+}
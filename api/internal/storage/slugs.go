@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// slugKeyPrefix indexes notes by slug. Each note's current slug and the
+// slugs it had before point at its ID; a note whose current slug differs
+// from the one looked up answers with a redirect.
+const slugKeyPrefix = indexKeyPrefix + "slug/"
+
+// transliterations spells out letters that have no ASCII base letter, or
+// are conventionally written differently. Other characters that are not
+// in baseLetters separate words.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "ae", 'œ': "oe", 'Œ': "oe", 'ø': "o", 'Ø': "o",
+	'ð': "d", 'Ð': "d", 'þ': "th", 'Þ': "th", 'ł': "l", 'Ł': "l", 'đ': "d", 'Đ': "d",
+	'ı': "i", 'ä': "ae", 'Ä': "ae", 'ö': "oe", 'Ö': "oe", 'ü': "ue", 'Ü': "ue",
+}
+
+// baseLetters maps Latin letters with diacritics to their base letter.
+var baseLetters = map[string]string{
+	"àáâãåāăą": "a", "çćĉċč": "c", "ďḍ": "d", "èéêëēĕėęě": "e", "ĝğġģ": "g",
+	"ĥħ": "h", "ìíîïĩīĭįİ": "i", "ĵ": "j", "ķ": "k", "ĺļľŀ": "l", "ñńņňŉ": "n",
+	"òóôõōŏő": "o", "ŕŗř": "r", "śŝşšș": "s", "ţťŧț": "t", "ùúûũūŭůűų": "u",
+	"ŵ": "w", "ýÿŷ": "y", "źżž": "z",
+}
+
+var baseLetter = func() map[rune]string {
+	m := make(map[rune]string)
+	for letters, base := range baseLetters {
+		for _, r := range letters {
+			m[r] = base
+			m[unicode.ToUpper(r)] = base
+		}
+	}
+	return m
+}()
+
+// Slugify turns text into a URL slug: lowercase ASCII letters and digits
+// in words separated by single dashes. Latin letters are transliterated,
+// so "Café Über" becomes "cafe-ueber"; other characters separate words.
+func Slugify(text string) string {
+	var b strings.Builder
+	dash := false
+	write := func(s string) {
+		if dash && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		dash = false
+		b.WriteString(s)
+	}
+
+	for _, r := range text {
+		switch {
+		case r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			write(string(unicode.ToLower(r)))
+		case r == '&':
+			dash = true
+			write("and")
+			dash = true
+		case transliterations[r] != "":
+			write(transliterations[r])
+		case baseLetter[r] != "":
+			write(baseLetter[r])
+		default:
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// slugPath slugifies each segment of a path, dropping the empty ones.
+func slugPath(path string) string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if slug := Slugify(segment); slug != "" {
+			segments = append(segments, slug)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// baseSlug is the slug a note asks for: its "slug" field, else its folder
+// and title, else its ID without a .md extension.
+func baseSlug(note Note) string {
+	if slug, ok := note.Metadata["slug"].(string); ok && slugPath(slug) != "" {
+		return slugPath(slug)
+	}
+
+	dir, name := folderOf(note.ID)
+	name = strings.TrimSuffix(name, ".md")
+	if title, ok := note.Metadata["title"].(string); ok && Slugify(title) != "" {
+		name = title
+	}
+	if slug := slugPath(dir + "/" + name); slug != "" {
+		return slug
+	}
+	return "note"
+}
+
+// slugFor reports whether slug is base or base with a counter added to
+// deduplicate it.
+func slugFor(slug, base string) bool {
+	if slug == base {
+		return true
+	}
+	n, found := strings.CutPrefix(slug, base+"-")
+	if !found {
+		return false
+	}
+	i, err := strconv.Atoi(n)
+	return err == nil && i > 1 && strconv.Itoa(i) == n
+}
+
+// slugOwner returns the ID of the note the slug points at, "" if none.
+func (ns *NoteStore) slugOwner(slug string) (string, error) {
+	id, err := ns.store.Get(slugKeyPrefix + slug)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	return string(id), err
+}
+
+// slugAvailable reports whether the note id may take slug: no other
+// existing note holds it, now or from before, or has it as its ID.
+func (ns *NoteStore) slugAvailable(slug, id string) (bool, error) {
+	for _, segment := range strings.Split(slug, "/") {
+		if reservedNoteIDSegments[segment] {
+			return false, nil
+		}
+	}
+
+	owner, err := ns.slugOwner(slug)
+	if err != nil {
+		return false, err
+	}
+	for _, other := range []string{owner, slug} {
+		if other == "" || other == id {
+			continue
+		}
+		if _, err := ns.GetNote(other); err == nil {
+			return false, nil
+		} else if !errors.Is(err, ErrNoteNotFound) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// assignSlug sets the slug of note, which is previous republished. A note
+// keeps its slug as long as it still asks for the same one; otherwise it
+// gets the slug it asks for, with a counter added if another note holds
+// it. Earlier slugs keep pointing at the note.
+func (ns *NoteStore) assignSlug(note *Note, previous Note) error {
+	base := baseSlug(*note)
+
+	slug := ""
+	if previous.Slug != "" && slugFor(previous.Slug, base) {
+		available, err := ns.slugAvailable(previous.Slug, note.ID)
+		if err != nil {
+			return err
+		}
+		if available {
+			slug = previous.Slug
+		}
+	}
+	for n := 1; slug == ""; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, n)
+		}
+		available, err := ns.slugAvailable(candidate, note.ID)
+		if err != nil {
+			return err
+		}
+		if available {
+			slug = candidate
+		}
+	}
+
+	note.Slug = slug
+	return ns.store.Set(slugKeyPrefix+slug, []byte(note.ID))
+}
+
+// releaseSlug frees the current slug of a note that is unpublished.
+func (ns *NoteStore) releaseSlug(note Note) error {
+	if note.Slug == "" {
+		return nil
+	}
+	owner, err := ns.slugOwner(note.Slug)
+	if err != nil || owner != note.ID {
+		return err
+	}
+	return ns.store.Delete(slugKeyPrefix + note.Slug)
+}
+
+// moveSlugs points the slugs of the note from at the ID to.
+func (ns *NoteStore) moveSlugs(from, to string) error {
+	slugs, err := listIDs(ns.store, slugKeyPrefix)
+	if err != nil {
+		return err
+	}
+	for _, slug := range slugs {
+		owner, err := ns.slugOwner(slug)
+		if err != nil {
+			return err
+		}
+		if owner != from {
+			continue
+		}
+		if err := ns.store.Set(slugKeyPrefix+slug, []byte(to)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetNoteBySlug returns the note with the slug, now or before. Callers
+// compare the note's Slug to redirect from old slugs.
+func (ns *NoteStore) GetNoteBySlug(slug string) (Note, error) {
+	id, err := ns.slugOwner(slug)
+	if err != nil {
+		return Note{}, err
+	}
+	if id == "" {
+		return Note{}, ErrNoteNotFound
+	}
+	return ns.GetNote(id)
+}
+
+// assignAllSlugs gives the notes published before slugs existed one.
+func assignAllSlugs(store Store) error {
+	ns := NewNoteStore(store)
+	notes, err := ns.ListNotes()
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if note.Slug != "" {
+			continue
+		}
+		if err := ns.assignSlug(&note, note); err != nil {
+			return err
+		}
+		if err := ns.put(note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
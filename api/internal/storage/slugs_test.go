@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Q3 Plan":               "q3-plan",
+		"  Hello,  World!  ":    "hello-world",
+		"Café Über Straße":      "cafe-ueber-strasse",
+		"Łódź & Kraków":         "lodz-and-krakow",
+		"naïve_résumé--v2":      "naive-resume-v2",
+		"日本語":                   "",
+		"Smørrebrød (æblegrød)": "smorrebrod-aeblegrod",
+		"already-a-slug":        "already-a-slug",
+	}
+	for text, want := range tests {
+		if got := Slugify(text); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestNoteSlugs(t *testing.T) {
+	noteStore := NewNoteStore(NewMemoryStore())
+
+	save := func(id, content string) Note {
+		t.Helper()
+		if err := noteStore.SaveNote(Note{ID: id, Content: content}); err != nil {
+			t.Fatalf("Failed to save note %q: %v", id, err)
+		}
+		note, err := noteStore.GetNote(id)
+		if err != nil {
+			t.Fatalf("Failed to get note %q: %v", id, err)
+		}
+		return note
+	}
+
+	if note := save("Projects/Q3 Plan.md", "plan"); note.Slug != "projects/q3-plan" {
+		t.Errorf("Expected a slug from the path, got %q", note.Slug)
+	}
+	if note := save("Projects/Q3 Plan (copy).md", "---\ntitle: Q3 Plan\n---\ncopy"); note.Slug != "projects/q3-plan-2" {
+		t.Errorf("Expected a taken slug to be deduplicated, got %q", note.Slug)
+	}
+	if note := save("Projects/Q3 Plan (copy).md", "---\ntitle: Q3 Plan\n---\nedited"); note.Slug != "projects/q3-plan-2" {
+		t.Errorf("Expected the slug to stay stable on republish, got %q", note.Slug)
+	}
+	if note := save("Projects/Q3 Plan (copy).md", "---\nslug: quarterly/q3\n---\nedited"); note.Slug != "quarterly/q3" {
+		t.Errorf("Expected the slug field to be used, got %q", note.Slug)
+	}
+
+	for slug, slugNow := range map[string]string{
+		"projects/q3-plan":   "projects/q3-plan",
+		"quarterly/q3":       "quarterly/q3",
+		"projects/q3-plan-2": "quarterly/q3",
+	} {
+		note, err := noteStore.GetNoteBySlug(slug)
+		if err != nil || note.Slug != slugNow {
+			t.Errorf("Expected slug %q to lead to the note at %q, got %q, %v", slug, slugNow, note.Slug, err)
+		}
+	}
+
+	if err := noteStore.DeleteNote("Projects/Q3 Plan.md"); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	if note := save("Other/Q3.md", "---\nslug: projects/q3-plan\n---\nnew"); note.Slug != "projects/q3-plan" {
+		t.Errorf("Expected the slug of a deleted note to be free, got %q", note.Slug)
+	}
+
+	if _, err := noteStore.MoveNote("Other/Q3.md", "Archive/Q3.md"); err != nil {
+		t.Fatalf("Failed to move note: %v", err)
+	}
+	if note, err := noteStore.GetNoteBySlug("projects/q3-plan"); err != nil || note.ID != "Archive/Q3.md" {
+		t.Errorf("Expected the slug to follow the moved note, got %+v, %v", note, err)
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore keeps every key in a single table of a SQLite database. Notes
+// are also indexed in an FTS5 table, see SearchNotes.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// A single connection serialises writers instead of failing them
+	// with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS kv (
+			key   TEXT PRIMARY KEY,
+			value BLOB NOT NULL
+		) WITHOUT ROWID;
+		CREATE VIRTUAL TABLE IF NOT EXISTS note_fts USING fts5(id UNINDEXED, title, content);
+	`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := &SQLiteStore{db: db}
+	if err := startNamespaced(store); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *SQLiteStore) Get(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRow(`SELECT value FROM kv WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *SQLiteStore) Set(key string, value []byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO kv (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value); err != nil {
+		return err
+	}
+
+	if id, ok := strings.CutPrefix(key, noteKeyPrefix); ok {
+		var note Note
+		if err := json.Unmarshal(value, &note); err == nil {
+			title, _ := note.Metadata["title"].(string)
+			if _, err := tx.Exec(`DELETE FROM note_fts WHERE id = ?`, id); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO note_fts (id, title, content) VALUES (?, ?, ?)`, id, title, note.Content); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Delete(key string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM kv WHERE key = ?`, key); err != nil {
+		return err
+	}
+	if id, ok := strings.CutPrefix(key, noteKeyPrefix); ok {
+		if _, err := tx.Exec(`DELETE FROM note_fts WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) ListKeys(prefix string) ([]string, error) {
+	// Keys compare bytewise, so the keys with a prefix are a contiguous
+	// range of the primary key.
+	query, args := `SELECT key FROM kv WHERE key >= ? ORDER BY key`, []interface{}{prefix}
+	if end, ok := prefixEnd(prefix); ok {
+		query, args = `SELECT key FROM kv WHERE key >= ? AND key < ? ORDER BY key`, append(args, end)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// prefixEnd returns the smallest key greater than every key starting with
+// prefix, if there is one.
+func prefixEnd(prefix string) (string, bool) {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1]), true
+		}
+	}
+	return "", false
+}
+
+// SearchNotes returns the IDs of the notes matching an FTS5 query over
+// their title and content, best matches first.
+func (s *SQLiteStore) SearchNotes(query string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT id FROM note_fts WHERE note_fts MATCH ? ORDER BY rank`, query)
+	if err != nil {
+		return nil, fmt.Errorf("search %q: %w", query, err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
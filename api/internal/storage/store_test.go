@@ -0,0 +1,192 @@
+package storage_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lutefd/md-publisher/api/internal/storage"
+	"github.com/lutefd/md-publisher/api/internal/storage/storetest"
+)
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "store-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestBadgerStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.Store {
+		store, err := storage.NewBadgerStore(tempDir(t))
+		if err != nil {
+			t.Fatalf("Failed to create BadgerStore: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.Store {
+		store, err := storage.NewSQLiteStore(filepath.Join(tempDir(t), "notes.db"))
+		if err != nil {
+			t.Fatalf("Failed to create SQLiteStore: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.Store {
+		return storage.NewMemoryStore()
+	})
+}
+
+func TestFileStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storage.Store {
+		store, err := storage.NewFileStore(tempDir(t))
+		if err != nil {
+			t.Fatalf("Failed to create FileStore: %v", err)
+		}
+		return store
+	})
+}
+
+func TestSQLiteStoreSearch(t *testing.T) {
+	store, err := storage.NewSQLiteStore(filepath.Join(tempDir(t), "notes.db"))
+	if err != nil {
+		t.Fatalf("Failed to create SQLiteStore: %v", err)
+	}
+	defer store.Close()
+
+	noteStore := storage.NewNoteStore(store)
+	noteStore.SaveNote(storage.Note{ID: "badger", Content: "---\ntitle: Storage\n---\nBadger is a key-value store."})
+	noteStore.SaveNote(storage.Note{ID: "sqlite", Content: "SQLite has full-text search."})
+
+	ids, err := store.SearchNotes("search")
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "sqlite" {
+		t.Errorf("Expected the sqlite note, got %v", ids)
+	}
+	if ids, _ := store.SearchNotes("storage"); len(ids) != 1 || ids[0] != "badger" {
+		t.Errorf("Expected titles to be searchable, got %v", ids)
+	}
+
+	noteStore.SaveNote(storage.Note{ID: "sqlite", Content: "Rewritten."})
+	if ids, _ := store.SearchNotes("search"); len(ids) != 0 {
+		t.Errorf("Expected the index to follow republished notes, got %v", ids)
+	}
+	noteStore.DeleteNote("badger")
+	if ids, _ := store.SearchNotes("badger"); len(ids) != 0 {
+		t.Errorf("Expected deleted notes to leave the index, got %v", ids)
+	}
+}
+
+func TestFileStoreLayout(t *testing.T) {
+	dir := tempDir(t)
+	store, err := storage.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create FileStore: %v", err)
+	}
+	noteStore := storage.NewNoteStore(store)
+
+	if err := noteStore.SaveNote(storage.Note{ID: "projects/plan", Content: "---\ntitle: Plan\n---\nShip it."}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "projects", "plan.md"))
+	if err != nil {
+		t.Fatalf("Expected the note as a Markdown file: %v", err)
+	}
+	if string(data) != "---\ntitle: Plan\n---\nShip it." {
+		t.Errorf("Unexpected file contents %q", data)
+	}
+
+	os.WriteFile(filepath.Join(dir, "dropped.md"), []byte("---\ntitle: Dropped\n---\nAdded by hand."), 0o644)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0o755)
+	os.WriteFile(filepath.Join(dir, ".git", "HEAD.md"), []byte("ignored"), 0o644)
+
+	note, err := noteStore.GetNote("dropped")
+	if err != nil || note.Metadata["title"] != "Dropped" {
+		t.Errorf("Expected a file added by hand to be a note, got %+v, %v", note, err)
+	}
+	if notes, _ := noteStore.ListNotes(); len(notes) != 2 {
+		t.Errorf("Expected 2 notes, got %v", notes)
+	}
+
+	reopened, err := storage.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen FileStore: %v", err)
+	}
+	if note, err := storage.NewNoteStore(reopened).GetNote("projects/plan"); err != nil || note.Slug != "projects/plan" {
+		t.Errorf("Expected the slug to be kept outside the note's file, got %+v, %v", note, err)
+	}
+	if keys, _ := store.ListKeys("idx/"); strings.Join(keys, ",") != "idx/slug/projects/plan" {
+		t.Errorf("Expected slugs not to be listed as keys, got %v", keys)
+	}
+
+	noteStore.DeleteNote("projects/plan")
+	if _, err := os.Stat(filepath.Join(dir, "projects")); !os.IsNotExist(err) {
+		t.Errorf("Expected the emptied folder to be removed, got %v", err)
+	}
+
+	for _, id := range []string{"../escape", ".hidden", "a//b"} {
+		if err := store.Set("note/"+id, []byte(`{"id":"x","content":"x"}`)); !errors.Is(err, storage.ErrInvalidKey) {
+			t.Errorf("Expected %q to be rejected, got %v", id, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "escape.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file outside the store, got %v", err)
+	}
+}
+
+func TestFileStoreOverExistingVault(t *testing.T) {
+	dir := tempDir(t)
+	os.WriteFile(filepath.Join(dir, "existing.md"), []byte("Written in the editor."), 0o644)
+
+	store, err := storage.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create FileStore: %v", err)
+	}
+	if _, _, err := storage.Migrate(store); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	note, err := storage.NewNoteStore(store).GetNote("existing")
+	if err != nil || note.Content != "Written in the editor." {
+		t.Errorf("Expected the existing file to stay in place, got %+v, %v", note, err)
+	}
+}
+
+func TestFileStoreRestoresSlugs(t *testing.T) {
+	dir := tempDir(t)
+	store, err := storage.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create FileStore: %v", err)
+	}
+	if err := storage.NewNoteStore(store).SaveNote(storage.Note{ID: "Projects/Q3 Plan.md", Content: "plan"}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+
+	// Stores written before slugs were kept have none for their notes.
+	os.RemoveAll(filepath.Join(dir, ".publisher", ".slugs"))
+	store.Set("meta/schema_version", []byte("6"))
+	if _, _, err := storage.Migrate(store); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	note, err := storage.NewNoteStore(store).GetNote("Projects/Q3 Plan.md")
+	if err != nil || note.Slug != "projects/q3-plan" {
+		t.Errorf("Expected the note to get its slug back, got %+v, %v", note, err)
+	}
+}
@@ -0,0 +1,223 @@
+// Package storetest checks that a storage.Store implementation behaves
+// like the others. Every backend runs the suite from its own tests:
+//
+//	func TestMyStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) storage.Store {
+//			return newMyStore(t)
+//		})
+//	}
+package storetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/lutefd/md-publisher/api/internal/storage"
+)
+
+// Run runs the conformance suite. open must return a new, empty store and
+// arrange for it to be closed when the test ends.
+func Run(t *testing.T, open func(t *testing.T) storage.Store) {
+	t.Run("MissingKey", func(t *testing.T) { testMissingKey(t, open(t)) })
+	t.Run("CRUD", func(t *testing.T) { testCRUD(t, open(t)) })
+	t.Run("ListKeys", func(t *testing.T) { testListKeys(t, open(t)) })
+	t.Run("ConcurrentWrites", func(t *testing.T) { testConcurrentWrites(t, open(t)) })
+	t.Run("LargeValue", func(t *testing.T) { testLargeValue(t, open(t)) })
+	t.Run("Notes", func(t *testing.T) { testNotes(t, open(t)) })
+	t.Run("Migrate", func(t *testing.T) { testMigrate(t, open(t)) })
+}
+
+func testMissingKey(t *testing.T, store storage.Store) {
+	if _, err := store.Get("sys/missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := store.Delete("sys/missing"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, got %v", err)
+	}
+}
+
+func testCRUD(t *testing.T, store storage.Store) {
+	key := "sys/test/a key with spaces%and/slashes"
+
+	for _, value := range []string{"first", "second", ""} {
+		if err := store.Set(key, []byte(value)); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+		got, err := store.Get(key)
+		if err != nil {
+			t.Fatalf("Failed to get value: %v", err)
+		}
+		if string(got) != value {
+			t.Errorf("Expected %q, got %q", value, got)
+		}
+	}
+
+	value := []byte("original")
+	store.Set(key, value)
+	value[0] = 'X'
+	if got, _ := store.Get(key); string(got) != "original" {
+		t.Errorf("Expected the store to keep its own copy of the value, got %q", got)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, err := store.Get(key); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected deleted key to be gone, got %v", err)
+	}
+}
+
+func testListKeys(t *testing.T, store storage.Store) {
+	for _, key := range []string{"sys/b", "idx/test", "sys/a/2", "sys/a/1", "sys/A", "sysx"} {
+		if err := store.Set(key, []byte("v")); err != nil {
+			t.Fatalf("Failed to set %q: %v", key, err)
+		}
+	}
+
+	keys, err := store.ListKeys("sys/")
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if want := []string{"sys/A", "sys/a/1", "sys/a/2", "sys/b"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected keys in byte order %v, got %v", want, keys)
+	}
+
+	if keys, err := store.ListKeys("nothing/"); err != nil || len(keys) != 0 {
+		t.Errorf("Expected no keys for an unused prefix, got %v, %v", keys, err)
+	}
+
+	all, err := store.ListKeys("")
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	for _, key := range []string{"idx/test", "sys/b", "sysx"} {
+		found := false
+		for _, k := range all {
+			found = found || k == key
+		}
+		if !found {
+			t.Errorf("Expected %q in the full listing %v", key, all)
+		}
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i-1] >= all[i] {
+			t.Errorf("Expected the full listing in byte order, got %v", all)
+			break
+		}
+	}
+}
+
+func testConcurrentWrites(t *testing.T, store storage.Store) {
+	const writers, keysPerWriter = 8, 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*keysPerWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keysPerWriter; i++ {
+				key := fmt.Sprintf("sys/concurrent/%d/%02d", w, i)
+				if err := store.Set(key, []byte(key)); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := store.ListKeys("sys/concurrent/"); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Concurrent write failed: %v", err)
+	}
+
+	keys, err := store.ListKeys("sys/concurrent/")
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(keys) != writers*keysPerWriter {
+		t.Fatalf("Expected %d keys, got %d", writers*keysPerWriter, len(keys))
+	}
+	for _, key := range keys {
+		if value, err := store.Get(key); err != nil || string(value) != key {
+			t.Errorf("Expected %q to hold its own name, got %q, %v", key, value, err)
+		}
+	}
+}
+
+func testLargeValue(t *testing.T, store storage.Store) {
+	value := bytes.Repeat([]byte("0123456789abcdef"), 256*1024)
+
+	if err := store.Set("sys/large", value); err != nil {
+		t.Fatalf("Failed to set a %d byte value: %v", len(value), err)
+	}
+	got, err := store.Get("sys/large")
+	if err != nil {
+		t.Fatalf("Failed to get large value: %v", err)
+	}
+	if !bytes.Equal(got, value) {
+		t.Errorf("Expected the large value back intact, got %d bytes", len(got))
+	}
+}
+
+func testNotes(t *testing.T, store storage.Store) {
+	noteStore := storage.NewNoteStore(store)
+
+	note := storage.Note{
+		ID:      "projects/plan",
+		Content: "---\ntitle: Plan\ntags: [work]\n---\n# Plan\n\nShip it.",
+	}
+	if err := noteStore.SaveNote(note); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+	if err := noteStore.SaveNote(storage.Note{ID: "welcome", Content: "Hello"}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+
+	got, err := noteStore.GetNote("projects/plan")
+	if err != nil {
+		t.Fatalf("Failed to get note: %v", err)
+	}
+	if got.Content != "# Plan\n\nShip it." || got.Metadata["title"] != "Plan" {
+		t.Errorf("Unexpected note %+v", got)
+	}
+	if tags, _ := got.Metadata["tags"].([]interface{}); len(tags) != 1 || tags[0] != "work" {
+		t.Errorf("Expected tags to survive, got %v", got.Metadata["tags"])
+	}
+	if got.Slug != "projects/plan" {
+		t.Errorf("Expected the note to keep its slug, got %q", got.Slug)
+	}
+	if bySlug, err := noteStore.GetNoteBySlug("projects/plan"); err != nil || bySlug.ID != "projects/plan" {
+		t.Errorf("Expected the note at its slug, got %+v, %v", bySlug, err)
+	}
+
+	notes, err := noteStore.ListNotes()
+	if err != nil {
+		t.Fatalf("Failed to list notes: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Errorf("Expected 2 notes, got %d", len(notes))
+	}
+
+	if err := noteStore.DeleteNote("projects/plan"); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	if _, err := noteStore.GetNote("projects/plan"); !errors.Is(err, storage.ErrNoteNotFound) {
+		t.Errorf("Expected deleted note to be gone, got %v", err)
+	}
+}
+
+func testMigrate(t *testing.T, store storage.Store) {
+	if _, to, err := storage.Migrate(store); err != nil || to != 7 {
+		t.Errorf("Expected a fresh store to migrate to version 7, got %d, %v", to, err)
+	}
+}
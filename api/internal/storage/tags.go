@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// tagKeyPrefix indexes notes by tag, as idx/tag/<escaped tag>/<id>. The
+// tag is path-escaped, so nested tags such as project/alpha stay one
+// segment and a prefix lists a tag with the tags nested in it.
+const tagKeyPrefix = indexKeyPrefix + "tag/"
+
+// inlineTag matches a #tag in Markdown text, as Obsidian reads them:
+// letters, digits, "_", "-" and "/" after a "#" that starts a word.
+var inlineTag = regexp.MustCompile(`(?:^|[\s(\[,;])#([\p{L}\p{N}_/-]*[\p{L}_/-][\p{L}\p{N}_/-]*)`)
+
+// NormalizeTag returns the form tags are indexed and compared in:
+// lowercase, without a leading "#" or surrounding slashes.
+func NormalizeTag(tag string) string {
+	return strings.Trim(strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#")), "/")
+}
+
+// Tags returns the tags of a note, normalized and sorted: those of its
+// "tags" field and the #tags in its content outside code.
+func (n Note) Tags() []string {
+	var tags []string
+	add := func(tag string) {
+		if tag = NormalizeTag(tag); tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	switch field := n.Metadata["tags"].(type) {
+	case string:
+		add(field)
+	case []string:
+		for _, tag := range field {
+			add(tag)
+		}
+	case []interface{}:
+		for _, tag := range field {
+			if s, ok := tag.(string); ok {
+				add(s)
+			}
+		}
+	}
+
+	fence := ""
+	for _, line := range strings.Split(n.Content, "\n") {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := codeFence.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			continue
+		}
+		for _, m := range inlineTag.FindAllStringSubmatch(stripInlineCode(line), -1) {
+			add(m[1])
+		}
+	}
+
+	slices.Sort(tags)
+	return tags
+}
+
+// stripInlineCode drops `code` spans from a line.
+func stripInlineCode(line string) string {
+	for {
+		start := strings.IndexByte(line, '`')
+		if start < 0 {
+			return line
+		}
+		end := strings.IndexByte(line[start+1:], '`')
+		if end < 0 {
+			return line
+		}
+		line = line[:start] + line[start+1+end+1:]
+	}
+}
+
+func tagKey(tag, id string) string {
+	return tagKeyPrefix + url.PathEscape(tag) + "/" + id
+}
+
+// indexTags points the tags of note at it, and drops the tags previous
+// had but note no longer has.
+func (ns *NoteStore) indexTags(previous, note Note) error {
+	tags := note.Tags()
+	for _, tag := range previous.Tags() {
+		if slices.Contains(tags, tag) && previous.ID == note.ID {
+			continue
+		}
+		if err := ns.store.Delete(tagKey(tag, previous.ID)); err != nil {
+			return err
+		}
+	}
+	for _, tag := range tags {
+		if err := ns.store.Set(tagKey(tag, note.ID), []byte(note.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TaggedNoteIDs returns the IDs of the notes with the tag or a tag nested
+// in it, so "project" finds notes tagged project/alpha too.
+func (ns *NoteStore) TaggedNoteIDs(tag string) ([]string, error) {
+	tag = NormalizeTag(tag)
+	var ids []string
+	for _, prefix := range []string{url.PathEscape(tag) + "/", url.PathEscape(tag + "/")} {
+		keys, err := listIDs(ns.store, tagKeyPrefix+prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if prefix != url.PathEscape(tag)+"/" {
+				// Nested tags: skip the rest of the escaped tag.
+				_, key, _ = strings.Cut(key, "/")
+			}
+			if key != "" && !slices.Contains(ids, key) {
+				ids = append(ids, key)
+			}
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// indexAllTags builds the tag index for notes published before it
+// existed.
+func indexAllTags(store Store) error {
+	ns := NewNoteStore(store)
+	notes, err := ns.ListNotes()
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if err := ns.indexTags(Note{}, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexTags drops the tag index entries of a note that is unpublished.
+func (ns *NoteStore) unindexTags(note Note) error {
+	for _, tag := range note.Tags() {
+		if err := ns.store.Delete(tagKey(tag, note.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNoteTags(t *testing.T) {
+	note := Note{
+		Metadata: map[string]interface{}{"tags": []interface{}{"Project/Alpha", "#draft"}},
+		Content:  "Notes on #research and (#Draft).\n\nNot a tag: a#b, #123, `#code` or\n\n```\n#fenced\n```\n#nested/tag/",
+	}
+	if tags := strings.Join(note.Tags(), ","); tags != "draft,nested/tag,project/alpha,research" {
+		t.Errorf("Unexpected tags %q", tags)
+	}
+}
+
+func TestTaggedNoteIDs(t *testing.T) {
+	noteStore := NewNoteStore(NewMemoryStore())
+	for _, note := range []Note{
+		{ID: "alpha", Content: "---\ntags: [project/alpha]\n---\nFirst."},
+		{ID: "beta", Content: "Second, #project."},
+		{ID: "other", Content: "Third, #projects."},
+	} {
+		if err := noteStore.SaveNote(note); err != nil {
+			t.Fatalf("Failed to save note %q: %v", note.ID, err)
+		}
+	}
+
+	check := func(tag, expected string) {
+		t.Helper()
+		ids, err := noteStore.TaggedNoteIDs(tag)
+		if err != nil || strings.Join(ids, ",") != expected {
+			t.Errorf("Expected %q to tag %q, got %v, %v", tag, expected, ids, err)
+		}
+	}
+	check("project", "alpha,beta")
+	check("#Project/Alpha", "alpha")
+	check("projects", "other")
+
+	if err := noteStore.SaveNote(Note{ID: "beta", Content: "Untagged now."}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+	check("project", "alpha")
+
+	if err := noteStore.DeleteNote("alpha"); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	check("project", "")
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// taskKeyPrefix indexes the tasks of each note, as idx/task/<id> holding
+// them as JSON.
+const taskKeyPrefix = indexKeyPrefix + "task/"
+
+// TaskStatus is the state of a task, from the character between its
+// brackets.
+type TaskStatus string
+
+const (
+	TaskOpen       TaskStatus = "open"        // "- [ ]"
+	TaskInProgress TaskStatus = "in_progress" // "- [/]"
+	TaskDone       TaskStatus = "done"        // "- [x]"
+	TaskCancelled  TaskStatus = "cancelled"   // "- [-]"
+)
+
+var ErrInvalidTaskStatus = errors.New("invalid task status")
+
+// ParseTaskStatus checks a task status given by a client.
+func ParseTaskStatus(status string) (TaskStatus, error) {
+	switch s := TaskStatus(status); s {
+	case TaskOpen, TaskInProgress, TaskDone, TaskCancelled:
+		return s, nil
+	}
+	return "", ErrInvalidTaskStatus
+}
+
+// Task is a Markdown task item of a note, "- [ ] text".
+type Task struct {
+	Text   string     `json:"text"`
+	Status TaskStatus `json:"status"`
+	// Due is the date the task is due, as YYYY-MM-DD, from a "📅 date" or
+	// "due: date" marker, which is dropped from Text.
+	Due string `json:"due,omitempty"`
+	// Line is the line of the task in the note's content, counted from 1
+	// and not counting frontmatter.
+	Line int `json:"line"`
+	// Heading is the heading of the section the task is in, if any.
+	Heading *Heading `json:"heading,omitempty"`
+	// Tags are the #tags in the task's own text.
+	Tags []string `json:"tags,omitempty"`
+}
+
+var (
+	taskItem = regexp.MustCompile(`^(?:[ \t]*>)*[ \t]*(?:[-*+]|\d+[.)])[ \t]+\[(.)\][ \t]+(.*)$`)
+	taskDue  = regexp.MustCompile(`(?:📅|\b[Dd]ue::?)[ \t]*(\d{4}-\d{2}-\d{2})\b`)
+)
+
+// Tasks returns the task items of Markdown content, outside code blocks,
+// with the heading each is under.
+func Tasks(content string) []Task {
+	headings, headingLines := headingLines(content)
+	tasks := []Task{}
+
+	fence := ""
+	section := -1
+	for i, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		for section+1 < len(headingLines) && headingLines[section+1] < i {
+			section++
+		}
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := codeFence.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			continue
+		}
+
+		m := taskItem.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		task := Task{Status: taskStatus(m[1]), Line: i + 1}
+		text := m[2]
+		if due := taskDue.FindStringSubmatchIndex(text); due != nil {
+			task.Due = text[due[2]:due[3]]
+			text = text[:due[0]] + text[due[1]:]
+		}
+		task.Text = strings.Join(strings.Fields(text), " ")
+		if section >= 0 {
+			heading := headings[section]
+			task.Heading = &heading
+		}
+		task.Tags = Note{Content: task.Text}.Tags()
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+func taskStatus(mark string) TaskStatus {
+	switch mark {
+	case "x", "X":
+		return TaskDone
+	case "-":
+		return TaskCancelled
+	case "/":
+		return TaskInProgress
+	}
+	return TaskOpen
+}
+
+// indexTasks stores the tasks of a note in the task index, or drops its
+// entry if it has none.
+func (ns *NoteStore) indexTasks(note Note) error {
+	tasks := Tasks(note.Content)
+	if len(tasks) == 0 {
+		return ns.store.Delete(taskKeyPrefix + note.ID)
+	}
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	}
+	return ns.store.Set(taskKeyPrefix+note.ID, data)
+}
+
+// indexAllTasks builds the task index for notes published before it
+// existed.
+func indexAllTasks(store Store) error {
+	ns := NewNoteStore(store)
+	notes, err := ns.ListNotes()
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if err := ns.indexTasks(note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TaskFilter selects the tasks ListTasks returns. Zero fields match every
+// task.
+type TaskFilter struct {
+	Status TaskStatus
+	// Tag matches the tasks with the tag in their text and every task of
+	// notes with the tag in their "tags" field, nested tags included.
+	Tag string
+}
+
+// NoteTask is a task along with the note it is in.
+type NoteTask struct {
+	Note Note
+	Task Task
+}
+
+// ListTasks returns the tasks of every note matching filter, for the
+// notes keep returns true for. Tasks with a due date come first, soonest
+// first, then the rest by note and line.
+func (ns *NoteStore) ListTasks(filter TaskFilter, keep func(Note) bool) ([]NoteTask, error) {
+	ids, err := listIDs(ns.store, taskKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	tag := NormalizeTag(filter.Tag)
+	hasTag := func(tags []string) bool {
+		return slices.ContainsFunc(tags, func(t string) bool { return t == tag || strings.HasPrefix(t, tag+"/") })
+	}
+
+	var results []NoteTask
+	for _, id := range ids {
+		// The index outlives notes that expire on their own.
+		note, err := ns.GetNote(id)
+		if errors.Is(err, ErrNoteNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if keep != nil && !keep(note) {
+			continue
+		}
+
+		data, err := ns.store.Get(taskKeyPrefix + id)
+		if err != nil {
+			return nil, err
+		}
+		var tasks []Task
+		if err := json.Unmarshal(data, &tasks); err != nil {
+			return nil, err
+		}
+
+		noteTagged := tag == "" || hasTag(Note{Metadata: note.Metadata}.Tags())
+		for _, task := range tasks {
+			if filter.Status != "" && task.Status != filter.Status {
+				continue
+			}
+			if !noteTagged && !hasTag(task.Tags) {
+				continue
+			}
+			results = append(results, NoteTask{Note: note, Task: task})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i].Task.Due, results[j].Task.Due
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		return a < b
+	})
+	return results, nil
+}
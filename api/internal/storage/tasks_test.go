@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTasks(t *testing.T) {
+	content := "- [ ] Before any heading\n" +
+		"# Launch\n" +
+		"- [x] Write the post #writing\n" +
+		"  * [/] Review it due: 2024-05-02\n" +
+		"1. [-] Tweet it\n" +
+		"```\n- [ ] not a task\n```\n" +
+		"Plans\n" +
+		"-----\n" +
+		"> - [ ] Book the venue 📅 2024-05-01 before noon\n" +
+		"- [] not a task either\n" +
+		"- [ ]no space\n"
+
+	launch := &Heading{Level: 1, Text: "Launch", Anchor: "launch"}
+	plans := &Heading{Level: 2, Text: "Plans", Anchor: "plans"}
+	want := []Task{
+		{Text: "Before any heading", Status: TaskOpen, Line: 1},
+		{Text: "Write the post #writing", Status: TaskDone, Line: 3, Heading: launch, Tags: []string{"writing"}},
+		{Text: "Review it", Status: TaskInProgress, Due: "2024-05-02", Line: 4, Heading: launch},
+		{Text: "Tweet it", Status: TaskCancelled, Line: 5, Heading: launch},
+		{Text: "Book the venue before noon", Status: TaskOpen, Due: "2024-05-01", Line: 11, Heading: plans},
+	}
+	if got := Tasks(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("Tasks() = %+v, want %+v", got, want)
+	}
+}
+
+func TestListTasks(t *testing.T) {
+	noteStore := NewNoteStore(NewMemoryStore())
+	for _, note := range []Note{
+		{ID: "launch", Content: "---\ntags: [project/launch]\n---\n- [ ] Ship it 📅 2024-06-01\n- [x] Plan it\n"},
+		{ID: "chores", Content: "- [ ] Water the plants\n- [ ] Launch the #project/site due: 2024-05-01\n"},
+		{ID: "plain", Content: "No tasks #project."},
+		{ID: "draft", Content: "---\ndraft: true\n---\n- [ ] Hidden"},
+	} {
+		if err := noteStore.SaveNote(note); err != nil {
+			t.Fatalf("Failed to save note %q: %v", note.ID, err)
+		}
+	}
+
+	list := func(filter TaskFilter) string {
+		t.Helper()
+		results, err := noteStore.ListTasks(filter, func(note Note) bool { return !note.IsDraft() })
+		if err != nil {
+			t.Fatalf("Failed to list tasks: %v", err)
+		}
+		var tasks []string
+		for _, result := range results {
+			tasks = append(tasks, result.Note.ID+":"+result.Task.Text)
+		}
+		return strings.Join(tasks, ",")
+	}
+
+	if got := list(TaskFilter{}); got != "chores:Launch the #project/site,launch:Ship it,chores:Water the plants,launch:Plan it" {
+		t.Errorf("Unexpected tasks %q", got)
+	}
+	if got := list(TaskFilter{Status: TaskOpen, Tag: "project"}); got != "chores:Launch the #project/site,launch:Ship it" {
+		t.Errorf("Unexpected open project tasks %q", got)
+	}
+	if got := list(TaskFilter{Tag: "#project/launch"}); got != "launch:Ship it,launch:Plan it" {
+		t.Errorf("Unexpected launch tasks %q", got)
+	}
+
+	if err := noteStore.SaveNote(Note{ID: "launch", Content: "- [x] Ship it"}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+	if err := noteStore.DeleteNote("chores"); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+	if got := list(TaskFilter{Status: TaskOpen}); got != "" {
+		t.Errorf("Expected no open tasks, got %q", got)
+	}
+	if _, err := noteStore.store.Get(taskKeyPrefix + "chores"); err == nil {
+		t.Error("Expected the tasks of a deleted note to leave the index")
+	}
+}
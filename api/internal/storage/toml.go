@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tomlSyntaxError is a TOML document that could not be decoded, at a line
+// and column of the document, both counted from 1.
+type tomlSyntaxError struct {
+	Line, Column int
+	Message      string
+}
+
+func (e *tomlSyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// decodeTOML decodes a TOML document into a map, for +++ frontmatter.
+// Integers decode to ints and dates and times to their RFC 3339 strings,
+// the way YAML and JSON frontmatter hold them.
+func decodeTOML(data string) (map[string]interface{}, error) {
+	var document map[string]interface{}
+	if _, err := toml.Decode(data, &document); err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &tomlSyntaxError{
+				Line:    parseErr.Position.Line,
+				Column:  max(parseErr.Position.Col, 1),
+				Message: parseErr.Message,
+			}
+		}
+		return nil, err
+	}
+	return tomlValue(document).(map[string]interface{}), nil
+}
+
+// tomlValue converts a value decoded by the TOML decoder into the types
+// the other frontmatter formats decode to.
+func tomlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int64:
+		return int(v)
+	case time.Time:
+		switch v.Location().String() {
+		case "date-local":
+			return v.Format(time.DateOnly)
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05.999999999")
+		case "time-local":
+			return v.Format("15:04:05.999999999")
+		}
+		return v.Format(time.RFC3339Nano)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = tomlValue(item)
+		}
+		return values
+	case []map[string]interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = tomlValue(item)
+		}
+		return values
+	case map[string]interface{}:
+		table := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			table[key] = tomlValue(item)
+		}
+		return table
+	}
+	return value
+}
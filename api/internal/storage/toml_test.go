@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeTOML(t *testing.T) {
+	data := `# Hugo style frontmatter
+title = "Hello \"TOML\" \u00e9"
+path = 'C:\notes'
+draft = false
+weight = 1_000
+ratio = 0.5
+date = 2024-05-01
+published = 1979-05-27 07:32:00Z
+tags = [
+  "go", # a comment
+  'toml',
+]
+author = { name = "Ana", links = [] }
+site.name = "Notes"
+summary = """
+First line \
+  continued.
+"""
+raw = '''
+C:\raw'''
+
+[params]
+color = "blue"
+
+[[resources]]
+src = "a.png"
+
+[[resources]]
+src = "b.png"
+`
+	got, err := decodeTOML(data)
+	if err != nil {
+		t.Fatalf("decodeTOML() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"title":     `Hello "TOML" é`,
+		"path":      `C:\notes`,
+		"draft":     false,
+		"weight":    1000,
+		"ratio":     0.5,
+		"date":      "2024-05-01",
+		"published": "1979-05-27T07:32:00Z",
+		"tags":      []interface{}{"go", "toml"},
+		"author":    map[string]interface{}{"name": "Ana", "links": []interface{}{}},
+		"site":      map[string]interface{}{"name": "Notes"},
+		"summary":   "First line continued.\n",
+		"raw":       `C:\raw`,
+		"params":    map[string]interface{}{"color": "blue"},
+		"resources": []interface{}{
+			map[string]interface{}{"src": "a.png"},
+			map[string]interface{}{"src": "b.png"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeTOML() = %#v, want %#v", got, want)
+	}
+}
+
+func TestDecodeTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		line, column int
+	}{
+		{"missing value", "title = \n", 1, 9},
+		{"missing equals", "a = 1\ntitle \"x\"\n", 2, 7},
+		{"duplicate key", "a = 1\na = 2\n", 2, 7},
+		{"duplicate table", "[a]\nb = 1\n[a]\nc = 2\n", 3, 2},
+		{"table over array of tables", "[[a]]\n[a]\n", 2, 2},
+		{"too many closing quotes", "a = '''ab''''''\n", 1, 8},
+		{"unterminated string", "a = \"open\n", 1, 10},
+		{"trailing text", "a = 1 2\n", 1, 6},
+		{"unterminated array", "a = [1,\n", 1, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeTOML(tt.data)
+			var syntax *tomlSyntaxError
+			if !errors.As(err, &syntax) {
+				t.Fatalf("Expected a syntax error, got %v", err)
+			}
+			if syntax.Line != tt.line || syntax.Column != tt.column {
+				t.Errorf("Expected the error at %d:%d, got %v", tt.line, tt.column, err)
+			}
+		})
+	}
+}
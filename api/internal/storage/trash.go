@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const trashKeyPrefix = systemKeyPrefix + "trash/"
+
+var (
+	ErrNotInTrash = errors.New("note not in trash")
+	ErrNoteExists = errors.New("a note with this ID exists")
+)
+
+// TrashedNote is an unpublished note kept until it is restored or purged.
+type TrashedNote struct {
+	Note      Note      `json:"note"`
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy string    `json:"deleted_by,omitempty"`
+}
+
+// TrashNote unpublishes a note by moving it to the trash. The note keeps
+// its password so a restore brings it back as it was; its pending
+// schedule events are dropped, along with its aliases, tags and tasks
+// from the indexes, and its slug is freed. Trashing a note again
+// replaces the earlier copy.
+func (ns *NoteStore) TrashNote(id, actor string) error {
+	note, err := ns.GetNote(id)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(TrashedNote{
+		Note:      note,
+		DeletedAt: ns.now().UTC(),
+		DeletedBy: actor,
+	})
+	if err != nil {
+		return err
+	}
+	if err := ns.store.Set(trashKeyPrefix+id, data); err != nil {
+		return err
+	}
+
+	if err := ns.schedule.DeleteEntry(id); err != nil {
+		return err
+	}
+	if err := ns.unindexAliases(note); err != nil {
+		return err
+	}
+	if err := ns.unindexTags(note); err != nil {
+		return err
+	}
+	if err := ns.releaseSlug(note); err != nil {
+		return err
+	}
+	if err := ns.store.Delete(taskKeyPrefix + id); err != nil {
+		return err
+	}
+	return ns.store.Delete(noteKey(id))
+}
+
+func (ns *NoteStore) GetTrashedNote(id string) (TrashedNote, error) {
+	var trashed TrashedNote
+
+	data, err := ns.store.Get(trashKeyPrefix + id)
+	if errors.Is(err, ErrNotFound) {
+		return trashed, ErrNotInTrash
+	} else if err != nil {
+		return trashed, err
+	}
+
+	err = json.Unmarshal(data, &trashed)
+	return trashed, err
+}
+
+func (ns *NoteStore) ListTrash() ([]TrashedNote, error) {
+	ids, err := listIDs(ns.store, trashKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	trash := []TrashedNote{}
+	for _, id := range ids {
+		trashed, err := ns.GetTrashedNote(id)
+		if err != nil {
+			continue
+		}
+		trash = append(trash, trashed)
+	}
+	return trash, nil
+}
+
+// RestoreNote republishes a trashed note. It refuses to overwrite a note
+// published under the same ID since.
+func (ns *NoteStore) RestoreNote(id string) error {
+	trashed, err := ns.GetTrashedNote(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ns.GetNote(id); err == nil {
+		return ErrNoteExists
+	} else if !errors.Is(err, ErrNoteNotFound) {
+		return err
+	}
+
+	if err := ns.assignSlug(&trashed.Note, trashed.Note); err != nil {
+		return err
+	}
+	if err := ns.put(trashed.Note); err != nil {
+		return err
+	}
+	if err := ns.indexTags(Note{}, trashed.Note); err != nil {
+		return err
+	}
+	if err := ns.indexAliases(Note{}, trashed.Note); err != nil {
+		return err
+	}
+	return ns.store.Delete(trashKeyPrefix + id)
+}
+
+// PurgeNote permanently deletes a trashed note.
+func (ns *NoteStore) PurgeNote(id string) error {
+	if _, err := ns.GetTrashedNote(id); err != nil {
+		return err
+	}
+
+	if _, err := ns.GetNote(id); errors.Is(err, ErrNoteNotFound) {
+		if err := ns.access.DeleteNotePassword(id); err != nil {
+			return err
+		}
+	}
+	return ns.store.Delete(trashKeyPrefix + id)
+}
+
+// PurgeTrash permanently deletes the notes trashed before cutoff and
+// returns how many were purged.
+func (ns *NoteStore) PurgeTrash(cutoff time.Time) (int, error) {
+	trash, err := ns.ListTrash()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, trashed := range trash {
+		if !trashed.DeletedAt.Before(cutoff) {
+			continue
+		}
+		if err := ns.PurgeNote(trashed.Note.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
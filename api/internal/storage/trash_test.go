@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "trash-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	noteStore := NewNoteStore(store)
+	accessStore := NewAccessStore(store)
+
+	if err := noteStore.SaveNote(Note{ID: "secret", Content: "---\nvisibility: protected\npassword: hunter2\n---\nbody"}); err != nil {
+		t.Fatalf("Failed to save note: %v", err)
+	}
+
+	if err := noteStore.TrashNote("secret", "ci"); err != nil {
+		t.Fatalf("Failed to trash note: %v", err)
+	}
+	if err := noteStore.TrashNote("missing", "ci"); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected trashing a missing note to fail, got %v", err)
+	}
+
+	if _, err := noteStore.GetNote("secret"); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("Expected trashed note to be gone, got %v", err)
+	}
+	if notes, _ := noteStore.ListNotes(); len(notes) != 0 {
+		t.Errorf("Expected trash to stay out of the note listing, got %v", notes)
+	}
+
+	trash, err := noteStore.ListTrash()
+	if err != nil {
+		t.Fatalf("Failed to list trash: %v", err)
+	}
+	if len(trash) != 1 || trash[0].Note.ID != "secret" || trash[0].DeletedBy != "ci" || trash[0].DeletedAt.IsZero() {
+		t.Fatalf("Unexpected trash %+v", trash)
+	}
+
+	if err := noteStore.RestoreNote("secret"); err != nil {
+		t.Fatalf("Failed to restore note: %v", err)
+	}
+	if note, err := noteStore.GetNote("secret"); err != nil || note.Content != "body" {
+		t.Errorf("Expected restored note, got %+v, %v", note, err)
+	}
+	if _, err := accessStore.CheckPassword("secret", "hunter2"); err != nil {
+		t.Errorf("Expected restored note to keep its password, got %v", err)
+	}
+	if err := noteStore.RestoreNote("secret"); !errors.Is(err, ErrNotInTrash) {
+		t.Errorf("Expected second restore to fail, got %v", err)
+	}
+
+	noteStore.TrashNote("secret", "ci")
+	noteStore.SaveNote(Note{ID: "secret", Content: "new"})
+	if err := noteStore.RestoreNote("secret"); !errors.Is(err, ErrNoteExists) {
+		t.Errorf("Expected restore over a newer note to fail, got %v", err)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "purge-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewBadgerStore(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create BadgerStore: %v", err)
+	}
+	defer store.Close()
+
+	noteStore := NewNoteStore(store)
+	accessStore := NewAccessStore(store)
+	now := time.Now()
+
+	noteStore.SaveNote(Note{ID: "old", Content: "---\npassword: hunter2\nvisibility: protected\n---\nold"})
+	noteStore.SaveNote(Note{ID: "recent", Content: "recent"})
+
+	noteStore.now = func() time.Time { return now.Add(-48 * time.Hour) }
+	noteStore.TrashNote("old", "")
+	noteStore.now = func() time.Time { return now }
+	noteStore.TrashNote("recent", "")
+
+	purged, err := noteStore.PurgeTrash(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to purge trash: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected one note to be purged, got %d", purged)
+	}
+
+	trash, _ := noteStore.ListTrash()
+	if len(trash) != 1 || trash[0].Note.ID != "recent" {
+		t.Errorf("Expected only the recent note to remain, got %+v", trash)
+	}
+	if _, err := accessStore.PasswordRealm("old"); !errors.Is(err, ErrNoPassword) {
+		t.Errorf("Expected purged note's password to be removed, got %v", err)
+	}
+
+	if err := noteStore.PurgeNote("recent"); err != nil {
+		t.Fatalf("Failed to purge note: %v", err)
+	}
+	if err := noteStore.PurgeNote("recent"); !errors.Is(err, ErrNotInTrash) {
+		t.Errorf("Expected purging twice to fail, got %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNoteIDLength is the longest note ID, in bytes.
+const MaxNoteIDLength = 200
+
+var ErrInvalidNote = errors.New("invalid note")
+
+// noteIDForbidden are the characters note IDs may not contain. IDs are
+// usually vault paths, but end up in URLs and file names too.
+const noteIDForbidden = `\?#%*:|"<>`
+
+// reservedNoteIDSegments name note actions in URLs such as
+// /note/<id>/preview-links, so an ID containing them would be ambiguous.
+var reservedNoteIDSegments = map[string]bool{"preview-link": true, "preview-links": true}
+
+// noteDateFields are the metadata fields that must hold a date.
+var noteDateFields = []string{"date", "created", "updated", "publish_at", "expire_at"}
+
+// Violation is one problem found with a note.
+type Violation struct {
+	// Field is "id", "frontmatter" or "metadata.<key>".
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	// Line and Column locate frontmatter violations in the content.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+
+	err error
+}
+
+// ValidationError lists every violation found with a note. It matches
+// ErrInvalidNote, and the errors of the individual checks such as
+// ErrInvalidSchedule, with errors.Is.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Field + ": " + v.Message
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidNote, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := []error{ErrInvalidNote}
+	for _, v := range e.Violations {
+		if v.err != nil {
+			errs = append(errs, v.err)
+		}
+	}
+	return errs
+}
+
+// NormalizeNoteID returns the canonical form of a note ID: surrounding
+// whitespace and slashes are trimmed and repeated slashes collapsed.
+func NormalizeNoteID(id string) string {
+	id = strings.Trim(strings.TrimSpace(id), "/")
+	for strings.Contains(id, "//") {
+		id = strings.ReplaceAll(id, "//", "/")
+	}
+	return id
+}
+
+// ValidateNoteID checks that id is a canonical note ID: slash-separated
+// segments of printable characters other than those in noteIDForbidden,
+// none of them starting with a dot, padded with spaces or reserved, at
+// most MaxNoteIDLength bytes of UTF-8 in all.
+func ValidateNoteID(id string) []Violation {
+	violation := func(format string, args ...interface{}) []Violation {
+		return []Violation{{Field: "id", Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if id == "" {
+		return violation("is required")
+	}
+	if len(id) > MaxNoteIDLength {
+		return violation("must be at most %d bytes, got %d", MaxNoteIDLength, len(id))
+	}
+	if !utf8.ValidString(id) {
+		return violation("must be valid UTF-8")
+	}
+	if id != NormalizeNoteID(id) {
+		return violation("must not start or end with a slash or contain empty segments")
+	}
+
+	for _, segment := range strings.Split(id, "/") {
+		if reservedNoteIDSegments[segment] {
+			return violation("segment %q is reserved for the API", segment)
+		}
+		if strings.HasPrefix(segment, ".") {
+			return violation("segment %q must not start with a dot", segment)
+		}
+		if strings.TrimSpace(segment) != segment {
+			return violation("segment %q must not start or end with a space", segment)
+		}
+		for _, r := range segment {
+			if !unicode.IsPrint(r) || strings.ContainsRune(noteIDForbidden, r) {
+				return violation("segment %q must not contain %q", segment, r)
+			}
+		}
+	}
+	return nil
+}
+
+// validateNote checks a note whose frontmatter has been extracted: its ID,
+// visibility and schedule, that tags are a list of strings, aliases a
+// string or a list of strings, that the title and slug are strings, that
+// a password is not empty and that date fields hold dates. It returns
+// every violation.
+func validateNote(note Note) []Violation {
+	violations := ValidateNoteID(note.ID)
+
+	add := func(field string, err error, message string) {
+		violations = append(violations, Violation{Field: field, Message: message, err: err})
+	}
+
+	if err := ValidateVisibility(note); err != nil {
+		add("metadata.visibility", err, err.Error())
+	}
+
+	for _, key := range []string{"title", "slug"} {
+		if value, exists := note.Metadata[key]; exists && value != nil {
+			if _, ok := value.(string); !ok {
+				add("metadata."+key, nil, "must be a string")
+			}
+		}
+	}
+
+	if password, exists := note.Metadata["password"]; exists && (password == nil || password == "") {
+		add("metadata.password", nil, "must not be empty")
+	}
+
+	if tags, exists := note.Metadata["tags"]; exists && tags != nil && !isStringList(tags) {
+		add("metadata.tags", nil, "must be a list of strings")
+	}
+
+	if aliases, exists := note.Metadata["aliases"]; exists && aliases != nil {
+		if _, ok := aliases.(string); !ok && !isStringList(aliases) {
+			add("metadata.aliases", nil, "must be a string or a list of strings")
+		}
+	}
+
+	invalidDates := false
+	for _, key := range noteDateFields {
+		if value, exists := note.Metadata[key]; exists && value != nil {
+			if _, err := ParseTime(value); err != nil {
+				var wrapped error
+				if key == "publish_at" || key == "expire_at" {
+					wrapped = ErrInvalidSchedule
+					invalidDates = true
+				}
+				add("metadata."+key, wrapped, "must be a date: "+err.Error())
+			}
+		}
+	}
+	if !invalidDates {
+		if err := ValidateSchedule(note); err != nil {
+			add("metadata.expire_at", err, "must be after publish_at")
+		}
+	}
+	return violations
+}
+
+func isStringList(value interface{}) bool {
+	switch list := value.(type) {
+	case []string:
+		return true
+	case []interface{}:
+		for _, item := range list {
+			if _, ok := item.(string); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeNoteID(t *testing.T) {
+	tests := map[string]string{
+		"welcome":            "welcome",
+		" /docs//guide/ ":    "docs/guide",
+		"projects///2024/q1": "projects/2024/q1",
+	}
+	for id, want := range tests {
+		if got := NormalizeNoteID(id); got != want {
+			t.Errorf("NormalizeNoteID(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestValidateNoteID(t *testing.T) {
+	valid := []string{"welcome", "docs/guide", "2024-01-01_log", "v1.2/notes", "docs/_index", "my note", "café", "Projects/Q3 Plan.md", strings.Repeat("a", MaxNoteIDLength)}
+	for _, id := range valid {
+		if violations := ValidateNoteID(id); violations != nil {
+			t.Errorf("Expected %q to be valid, got %v", id, violations)
+		}
+	}
+
+	invalid := []string{"", "/docs", "docs//guide", "../escape", "docs/.hidden", "docs/ padded", "a?b", "a%2Fb", "a:b", "a\\b", "tab\there", "\xff", "docs/preview-links", strings.Repeat("a", MaxNoteIDLength+1)}
+	for _, id := range invalid {
+		if violations := ValidateNoteID(id); len(violations) != 1 || violations[0].Field != "id" {
+			t.Errorf("Expected %q to be rejected, got %v", id, violations)
+		}
+	}
+}
+
+func TestValidateNote(t *testing.T) {
+	note := Note{ID: "valid", Metadata: map[string]interface{}{
+		"title":   "Title",
+		"tags":    []interface{}{"a", "b"},
+		"date":    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		"updated": "2024-01-02T10:00:00Z",
+	}}
+	if err := DefaultMetadataSchema.ValidateNote(note); err != nil {
+		t.Errorf("Expected a valid note, got %v", err)
+	}
+
+	note = Note{ID: "bad:id", Metadata: map[string]interface{}{
+		"title":      42,
+		"tags":       "single",
+		"visibility": "secret",
+		"created":    "yesterday",
+		"publish_at": "2024-02-01T00:00:00Z",
+		"expire_at":  "2024-01-01T00:00:00Z",
+	}}
+	err := DefaultMetadataSchema.ValidateNote(note)
+
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, violation := range validation.Violations {
+		fields[violation.Field] = true
+	}
+	for _, field := range []string{"id", "metadata.title", "metadata.tags", "metadata.visibility", "metadata.created", "metadata.expire_at"} {
+		if !fields[field] {
+			t.Errorf("Expected a violation for %s, got %v", field, validation.Violations)
+		}
+	}
+	if !errors.Is(err, ErrInvalidNote) || !errors.Is(err, ErrInvalidVisibility) || !errors.Is(err, ErrInvalidSchedule) {
+		t.Errorf("Expected the error to match the failed checks, got %v", err)
+	}
+}
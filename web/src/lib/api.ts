@@ -1,5 +1,22 @@
+export interface TocEntry {
+	level: number;
+	text: string;
+	anchor: string;
+}
+
+export interface DerivedMetadata {
+	word_count: number;
+	char_count: number;
+	reading_time: number;
+	excerpt?: string;
+	image?: string;
+}
+
 export interface Note {
 	id: string;
+	slug?: string;
+	toc?: TocEntry[];
+	derived?: DerivedMetadata;
 	content: string;
 	metadata: {
 		title?: string;
@@ -10,6 +27,23 @@ export interface Note {
 	};
 }
 
+export interface QueryResult {
+	type: 'list' | 'table';
+	columns?: { name: string; label: string }[];
+	rows: { id: string; slug?: string; title: string; values?: unknown[] }[];
+}
+
+export interface Task {
+	text: string;
+	status: 'open' | 'in_progress' | 'done' | 'cancelled';
+	due?: string;
+	line: number;
+	heading?: TocEntry;
+	tags?: string[];
+	note: { id: string; slug?: string; title: string };
+	link: string;
+}
+
 let API_URL = '/api';
 
 if (typeof window === 'undefined') {
@@ -20,11 +54,32 @@ if (typeof window === 'undefined') {
 	}
 }
 
+/**
+ * The reader a page is rendered for: the cookies of their request, which
+ * hold their session and note access tokens, and the token of the preview
+ * link they followed, if any
+ */
+export interface Reader {
+	cookie?: string | null;
+	preview?: string | null;
+}
+
+/**
+ * The reader of a request to the site, to pass on to the API
+ */
+export function readerOf(request: Request, url?: URL): Reader {
+	return { cookie: request.headers.get('cookie'), preview: url?.searchParams.get('preview') };
+}
+
+function readerInit(reader?: Reader): RequestInit {
+	return reader?.cookie ? { headers: { cookie: reader.cookie } } : {};
+}
+
 /**
  * Fetch all published notes
  */
-export async function getAllNotes(): Promise<Note[]> {
-	const response = await fetch(`${API_URL}/notes`);
+export async function getAllNotes(reader?: Reader): Promise<Note[]> {
+	const response = await fetch(`${API_URL}/notes`, readerInit(reader));
 
 	if (!response.ok) {
 		throw new Error(`Failed to fetch notes: ${response.statusText}`);
@@ -34,10 +89,29 @@ export async function getAllNotes(): Promise<Note[]> {
 }
 
 /**
- * Fetch a specific note by ID
+ * The anchor of a heading, as the API derives it: lowercased, spaces
+ * turned into dashes and punctuation other than - and _ dropped
+ */
+export function headingAnchor(text: string): string {
+	return text
+		.toLowerCase()
+		.replace(/[^\p{L}\p{M}\p{N}\p{Pc} -]/gu, '')
+		.replace(/ /g, '-');
+}
+
+/**
+ * The canonical URL of a note, at its slug
  */
-export async function getNoteById(id: string): Promise<Note> {
-	const response = await fetch(`${API_URL}/note/${id}`);
+export function notePath(note: Pick<Note, 'id' | 'slug'>): string {
+	return `/note/${note.slug ?? note.id}`;
+}
+
+/**
+ * Fetch a specific note by ID or slug
+ */
+export async function getNoteById(id: string, reader?: Reader): Promise<Note> {
+	const preview = reader?.preview ? `?preview=${encodeURIComponent(reader.preview)}` : '';
+	const response = await fetch(`${API_URL}/note/${id}${preview}`, readerInit(reader));
 
 	if (!response.ok) {
 		throw new Error(`Failed to fetch note: ${response.statusText}`);
@@ -46,6 +120,42 @@ export async function getNoteById(id: string): Promise<Note> {
 	return response.json();
 }
 
+/**
+ * Run a query over the notes' metadata
+ */
+export async function runQuery(query: string, reader?: Reader): Promise<QueryResult> {
+	const response = await fetch(
+		`${API_URL}/query?q=${encodeURIComponent(query)}`,
+		readerInit(reader)
+	);
+
+	if (!response.ok) {
+		const body = await response.json().catch(() => undefined);
+		throw new Error(body?.error?.message ?? `Failed to run query: ${response.statusText}`);
+	}
+
+	return response.json();
+}
+
+/**
+ * Fetch the task items of published notes, optionally by status and tag
+ */
+export async function getTasks(
+	filter: { status?: string; tag?: string } = {},
+	reader?: Reader
+): Promise<Task[]> {
+	const params = new URLSearchParams();
+	if (filter.status) params.set('status', filter.status);
+	if (filter.tag) params.set('tag', filter.tag);
+	const response = await fetch(`${API_URL}/tasks?${params}`, readerInit(reader));
+
+	if (!response.ok) {
+		throw new Error(`Failed to fetch tasks: ${response.statusText}`);
+	}
+
+	return response.json();
+}
+
 /**
  * Process note content to separate frontmatter and body
  * This is useful when you want to display just the content without frontmatter
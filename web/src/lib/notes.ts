@@ -1,11 +1,11 @@
-import { getAllNotes, getNoteById, type Note } from './api';
+import { getAllNotes, getNoteById, type Note, type Reader } from './api';
 
 /**
- * Get all notes from the API
+ * Get all notes from the API that the reader may list
  */
-export async function getNotes(): Promise<Note[]> {
+export async function getNotes(reader?: Reader): Promise<Note[]> {
 	try {
-		const notes = await getAllNotes();
+		const notes = await getAllNotes(reader);
 		return notes;
 	} catch (error) {
 		console.error('Failed to fetch notes:', error);
@@ -14,11 +14,11 @@ export async function getNotes(): Promise<Note[]> {
 }
 
 /**
- * Get a note by ID
+ * Get a note by ID, as the reader may see it
  */
-export async function getNote(id: string): Promise<Note | undefined> {
+export async function getNote(id: string, reader?: Reader): Promise<Note | undefined> {
 	try {
-		return await getNoteById(id);
+		return await getNoteById(id, reader);
 	} catch (error) {
 		console.error(`Failed to fetch note ${id}:`, error);
 		return undefined;
@@ -1,9 +1,10 @@
+import { readerOf } from '$lib/api';
 import { getNotes } from '$lib/notes';
 import type { LayoutServerLoad } from './$types';
 
-export const load: LayoutServerLoad = async () => {
+export const load: LayoutServerLoad = async ({ request }) => {
 	try {
-		const notes = await getNotes();
+		const notes = await getNotes(readerOf(request));
 		return { notes };
 	} catch (error) {
 		console.error('Error loading notes in layout:', error);
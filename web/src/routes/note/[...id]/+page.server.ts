@@ -0,0 +1,150 @@
+import { getNote } from '$lib/notes';
+import {
+	headingAnchor,
+	notePath,
+	readerOf,
+	runQuery,
+	type QueryResult,
+	type Reader
+} from '$lib/api';
+import { Marked, marked, type Tokens, type TokenizerAndRendererExtension } from 'marked';
+import { error, redirect } from '@sveltejs/kit';
+
+// Renders [[target#heading|label]] links to the target note, pointing at
+// the heading's anchor.
+const wikiLink: TokenizerAndRendererExtension = {
+	name: 'wikiLink',
+	level: 'inline',
+	start: (src) => src.indexOf('[['),
+	tokenizer(src) {
+		const match = /^\[\[([^\]|#]*)(?:#([^\]|]*))?(?:\|([^\]]*))?\]\]/.exec(src);
+		if (!match) return;
+		const [raw, target, heading, label] = match;
+		return { type: 'wikiLink', raw, target: target.trim(), heading, label };
+	},
+	renderer(token) {
+		const path = token.target ? `/note/${encodeURI(token.target.replace(/\.md$/, ''))}` : '';
+		const anchor = token.heading ? `#${headingAnchor(token.heading.trim())}` : '';
+		const text = token.label || token.heading || token.target;
+		return `<a href="${path}${anchor}" class="internal-link">${marked.parseInline(text)}</a>`;
+	}
+};
+
+const markdown = new Marked({ extensions: [wikiLink] });
+
+function escapeHtml(text: string): string {
+	return text
+		.replace(/&/g, '&amp;')
+		.replace(/</g, '&lt;')
+		.replace(/>/g, '&gt;')
+		.replace(/"/g, '&quot;');
+}
+
+function formatValue(value: unknown): string {
+	if (value === null || value === undefined) return '';
+	if (Array.isArray(value)) return value.map(formatValue).join(', ');
+	if (typeof value === 'object') return JSON.stringify(value);
+	return String(value);
+}
+
+// Renders the results of a ```query block as a list or table of links to
+// the notes matched.
+function renderQuery(result: QueryResult | Error): string {
+	if (result instanceof Error) {
+		return `<p class="query-error">${escapeHtml(result.message)}</p>\n`;
+	}
+	if (result.rows.length === 0) {
+		return '<p class="query-empty">No notes match this query.</p>\n';
+	}
+
+	const link = (row: QueryResult['rows'][number]) =>
+		`<a href="${escapeHtml(encodeURI(notePath(row)))}" class="internal-link">${escapeHtml(row.title)}</a>`;
+	if (result.type === 'list') {
+		return `<ul class="query-list">${result.rows.map((row) => `<li>${link(row)}</li>`).join('')}</ul>\n`;
+	}
+
+	const head = ['Note', ...(result.columns ?? []).map((column) => column.label)]
+		.map((label) => `<th>${escapeHtml(label)}</th>`)
+		.join('');
+	const body = result.rows
+		.map((row) => {
+			const cells = (row.values ?? []).map((value) => `<td>${escapeHtml(formatValue(value))}</td>`);
+			return `<tr><td>${link(row)}</td>${cells.join('')}</tr>`;
+		})
+		.join('');
+	return `<div class="table-wrapper"><table class="query-table"><thead><tr>${head}</tr></thead><tbody>${body}</tbody></table></div>\n`;
+}
+
+// Runs the ```query blocks of a note for the reader, keyed by their text.
+async function runQueries(
+	content: string,
+	reader: Reader
+): Promise<Map<string, QueryResult | Error>> {
+	const queries = new Set<string>();
+	markdown.walkTokens(markdown.lexer(content), (token) => {
+		const code = token as Tokens.Code;
+		if (token.type === 'code' && code.lang?.trim() === 'query') queries.add(code.text);
+	});
+
+	const results = new Map<string, QueryResult | Error>();
+	await Promise.all(
+		[...queries].map(async (query) => {
+			results.set(query, await runQuery(query, reader).catch((err: Error) => err));
+		})
+	);
+	return results;
+}
+
+export async function load({ params, request, url }) {
+	const noteId = params.id;
+	// The API decides what the reader may see from their cookies and the
+	// preview token of a preview link, so both are passed on.
+	const reader = readerOf(request, url);
+	const note = await getNote(noteId, reader);
+
+	if (!note) {
+		throw error(404, 'Note not found');
+	}
+
+	// Notes are read by ID, old slug or alias too; send readers on to the
+	// note's canonical URL.
+	if (notePath(note) !== `/note/${noteId}`) {
+		throw redirect(301, notePath(note) + url.search);
+	}
+
+	try {
+		const renderer = new marked.Renderer();
+
+		const originalTable = renderer.table.bind(renderer);
+		renderer.table = function (table) {
+			const html = originalTable(table);
+			return `<div class="table-wrapper">${html}</div>`;
+		};
+
+		// Headings take their anchors from the table of contents the API
+		// returns, so the sidebar and [[Note#Heading]] links agree with them.
+		let heading = 0;
+		renderer.heading = function ({ tokens, depth, text }) {
+			const anchor = note.toc?.[heading++]?.anchor ?? headingAnchor(text);
+			return `<h${depth} id="${anchor}">${renderer.parser.parseInline(tokens)}</h${depth}>\n`;
+		};
+
+		// Query blocks are run before rendering, which is synchronous.
+		const queries = await runQueries(note.content, reader);
+		const originalCode = renderer.code.bind(renderer);
+		renderer.code = function (code) {
+			const result = code.lang?.trim() === 'query' ? queries.get(code.text) : undefined;
+			return result ? renderQuery(result) : originalCode(code);
+		};
+
+		const content = markdown.parse(note.content, { renderer });
+
+		return {
+			note,
+			content
+		};
+	} catch (err) {
+		console.error('Failed to load note:', err);
+		throw error(404, 'Note not found');
+	}
+}
@@ -1,9 +1,10 @@
+import { readerOf } from '$lib/api';
 import { getNotes } from '$lib/notes';
 import type { PageServerLoad } from './$types';
 
-export const load: PageServerLoad = async () => {
+export const load: PageServerLoad = async ({ request }) => {
 	try {
-		const notes = await getNotes();
+		const notes = await getNotes(readerOf(request));
 		return { notes };
 	} catch (error) {
 		console.error('Error loading notes:', error);
@@ -0,0 +1,14 @@
+import { getTasks, readerOf, type Task } from '$lib/api';
+import type { PageServerLoad } from './$types';
+
+export const load: PageServerLoad = async ({ url, request }) => {
+	const status = url.searchParams.get('status') ?? 'open';
+	const tag = url.searchParams.get('tag') ?? '';
+	try {
+		const tasks = await getTasks({ status, tag }, readerOf(request));
+		return { tasks, status, tag };
+	} catch (error) {
+		console.error('Error loading tasks:', error);
+		return { tasks: [] as Task[], status, tag };
+	}
+};